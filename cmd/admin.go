@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/admin"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/socket"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"go.uber.org/zap"
+)
+
+// adminSocketMode restricts the admin socket to the user of the plugin.
+const adminSocketMode = 0o600
+
+func (o *Options) validateAdminFlags() error {
+	if o.AdminAddress == "" {
+		return nil
+	}
+
+	s, err := socket.NewSocket(o.AdminAddress)
+	if err != nil {
+		return fmt.Errorf("invalid admin address: %w", err)
+	}
+
+	if !s.IsTCP() {
+		return nil
+	}
+
+	// already validated
+	host, _, _ := net.SplitHostPort(s.Path)
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errors.New("admin address must be a loopback address or a unix socket")
+	}
+
+	return nil
+}
+
+// redactedConfig returns the options by their environment variable, secrets are redacted.
+func (o *Options) redactedConfig() map[string]any {
+	config := map[string]any{}
+
+	v := reflect.ValueOf(o).Elem()
+
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		value := v.Field(i).Interface()
+
+		switch {
+		case field.Tag.Get("redact") == "true" && !v.Field(i).IsZero():
+			value = logging.Redacted
+		case v.Field(i).Kind() == reflect.String:
+			// e.g. a token passed as part of another option
+			value = logging.Redact(v.Field(i).String())
+		}
+
+		config[envPrefix+name] = value
+	}
+
+	return config
+}
+
+// adminServer listens for the admin endpoints, nil if they are disabled.
+func (o *Options) adminServer(level zap.AtomicLevel, vc *vault.Client) (*httpServer, error) {
+	if o.AdminAddress == "" {
+		return nil, nil //nolint: nilnil
+	}
+
+	// already validated
+	s, _ := socket.NewSocket(o.AdminAddress)
+
+	listener, err := s.Listen(o.ForceSocketOverwrite, socket.WithMode(adminSocketMode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on admin address %s: %w", s.Path, err)
+	}
+
+	zap.L().Info("Exposing admin endpoints /debug/pprof/, /loglevel, /config, /token", zap.String("address", s.Path))
+
+	return newHTTPServer(listener, admin.NewHandler(admin.Config{
+		LogLevel:  level,
+		Config:    o.redactedConfig(),
+		TokenInfo: vc.TokenInfo,
+	})), nil
+}
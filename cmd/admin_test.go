@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAdminFlags(t *testing.T) {
+	for _, addr := range []string{"", "tcp://127.0.0.1:6060", "tcp://localhost:6060", "tcp://[::1]:6060", "unix:///opt/kms/admin.socket"} {
+		require.NoError(t, (&Options{AdminAddress: addr}).validateAdminFlags(), addr)
+	}
+
+	for _, addr := range []string{"tcp://0.0.0.0:6060", "tcp://:6060", "tcp://10.0.0.1:6060", "127.0.0.1:6060"} {
+		require.Error(t, (&Options{AdminAddress: addr}).validateAdminFlags(), addr)
+	}
+}
+
+func TestRedactedConfig(t *testing.T) {
+	opts := &Options{
+		VaultAddress:        "https://vault:8200",
+		AuthMethod:          "approle",
+		Token:               "hvs.secret",
+		AppRoleRoleID:       "role",
+		AppRoleRoleSecretID: "secret-id",
+		BatchMaxSize:        64,
+	}
+
+	config := opts.redactedConfig()
+
+	require.Equal(t, "https://vault:8200", config["VAULT_KMS_VAULT_ADDR"])
+	require.Equal(t, "role", config["VAULT_KMS_APPROLE_ROLE_ID"])
+	require.Equal(t, 64, config["VAULT_KMS_BATCH_MAX_SIZE"])
+	require.Equal(t, logging.Redacted, config["VAULT_KMS_TOKEN"])
+	require.Equal(t, logging.Redacted, config["VAULT_KMS_APPROLE_SECRET_ID"])
+
+	// empty secrets are shown as unset
+	require.Empty(t, config["VAULT_KMS_USERPASS_PASSWORD"])
+
+	require.NotContains(t, config, "VAULT_KMS_VERSION")
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAuditKey(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.key")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("k", minAuditKeySize)+"\n"), 0o600))
+
+	return path
+}
+
+func TestValidateAuditFlags(t *testing.T) {
+	keyFile := writeAuditKey(t)
+
+	testCases := []struct {
+		name string
+		opts *Options
+		err  bool
+	}{
+		{
+			name: "disabled",
+			opts: &Options{},
+		},
+		{
+			name: "file",
+			opts: &Options{AuditSink: "file", AuditFile: "/var/log/kms/audit.log", AuditHMACKeyFile: keyFile},
+		},
+		{
+			name: "file without path",
+			opts: &Options{AuditSink: "file", AuditHMACKeyFile: keyFile},
+			err:  true,
+		},
+		{
+			name: "stdout without key",
+			opts: &Options{AuditSink: "stdout"},
+			err:  true,
+		},
+		{
+			name: "missing key file",
+			opts: &Options{AuditSink: "stdout", AuditHMACKeyFile: filepath.Join(t.TempDir(), "missing")},
+			err:  true,
+		},
+		{
+			name: "syslog without socket",
+			opts: &Options{AuditSink: "syslog", AuditHMACKeyFile: keyFile},
+			err:  true,
+		},
+		{
+			name: "invalid sink",
+			opts: &Options{AuditSink: "kafka", AuditHMACKeyFile: keyFile},
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.opts.validateAuditFlags()
+		if tc.err {
+			require.Error(t, err, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+	}
+
+	short := filepath.Join(t.TempDir(), "short.key")
+	require.NoError(t, os.WriteFile(short, []byte("short"), 0o600))
+
+	_, err := readAuditKey(short)
+	require.Error(t, err)
+}
+
+func TestVerifyAudit(t *testing.T) {
+	keyFile := writeAuditKey(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	opts := &Options{AuditSink: "file", AuditFile: path, AuditHMACKeyFile: keyFile}
+
+	logger, err := opts.auditLogger()
+	require.NoError(t, err)
+
+	logger.Log(&audit.Entry{Method: "Encrypt", Outcome: audit.OutcomeSuccess})
+	logger.Log(&audit.Entry{Method: "Decrypt", Outcome: audit.OutcomeSuccess})
+	require.NoError(t, logger.Close())
+
+	require.NoError(t, VerifyAudit([]string{"-hmac-key-file", keyFile, path}))
+
+	require.Error(t, VerifyAudit([]string{"-hmac-key-file", keyFile}), "no files")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), "Decrypt", "Encrypt", 1)), 0o600))
+
+	require.ErrorContains(t, VerifyAudit([]string{"-hmac-key-file", keyFile, path}), "invalid hmac")
+}
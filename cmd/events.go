@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/events"
+	"go.uber.org/zap"
+)
+
+func (o *Options) validateEventsFlags() error {
+	if !o.EventsEnabled {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(o.EventsInterval)
+	if err != nil {
+		return fmt.Errorf("invalid events interval: %w", err)
+	}
+
+	if interval <= 0 {
+		return errors.New("events interval must be positive")
+	}
+
+	if o.EventsObject != "" {
+		_, err = events.ParseObjectReference(o.EventsObject)
+		if err != nil {
+			return fmt.Errorf("invalid events object: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// eventReporter returns the reporter of Kubernetes Events, nil if events are disabled.
+func (o *Options) eventReporter() (*events.Reporter, error) {
+	if !o.EventsEnabled {
+		return nil, nil //nolint: nilnil
+	}
+
+	object := o.EventsObject
+	if object == "" {
+		object = events.DefaultObject()
+	}
+
+	// already validated
+	ref, _ := events.ParseObjectReference(object)
+	interval, _ := time.ParseDuration(o.EventsInterval)
+
+	client, err := events.NewClientset(o.EventsKubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	zap.L().Info("Reporting kubernetes events", zap.String("object", object), zap.String("interval", o.EventsInterval))
+
+	return events.NewReporter(client, ref, interval), nil
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/certs"
+	customHTTP "github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/http"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/probes"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/socket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const readHeaderTimeout = 3 * time.Second
+
+func (o *Options) validateHTTPFlags() error {
+	if o.HealthSocket != "" {
+		s, err := socket.NewSocket(o.HealthSocket)
+		if err != nil {
+			return fmt.Errorf("invalid health socket: %w", err)
+		}
+
+		if s.IsTCP() {
+			return errors.New("health socket must be a unix socket")
+		}
+	}
+
+	if o.MetricsPort != "" && o.MetricsPort == o.HealthPort && o.HealthSocket == "" {
+		return errors.New("metrics port must differ from the health port")
+	}
+
+	if (o.MetricsTLSCertFile == "") != (o.MetricsTLSKeyFile == "") {
+		return errors.New("metrics tls cert and key are both required")
+	}
+
+	if o.MetricsTLSClientCAFile != "" && o.MetricsTLSCertFile == "" {
+		return errors.New("metrics tls client ca requires a metrics tls cert and key")
+	}
+
+	return nil
+}
+
+// httpServer serves the probe and metrics endpoints on a listener.
+type httpServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// httpServers listens for the probe and metrics endpoints. Both share the health port, unless the probes
+// are served on a unix socket or the metrics on their own port. TLS applies to the listener serving /metrics,
+// authentication only to /metrics, so that the kubelet can still probe a shared port without credentials.
+func (o *Options) httpServers(healthChecks []probes.Prober) ([]*httpServer, error) {
+	metricsHandler := o.metricsHandler()
+
+	tlsConfig, err := o.metricsTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	probeMux := &http.ServeMux{}
+	probeMux.HandleFunc("/health", customHTTP.LoggingMiddleware(probes.HealthZ(healthChecks)))
+	probeMux.HandleFunc("/live", customHTTP.LoggingMiddleware(probes.HealthZ(healthChecks)))
+
+	healthAddress := net.JoinHostPort(o.HealthBindAddress, o.HealthPort)
+
+	var probeServer *httpServer
+
+	switch {
+	case o.HealthSocket == "" && o.MetricsPort == "":
+		probeMux.HandleFunc("/metrics", metricsHandler)
+
+		probeServer, err = listenHTTP("/health, /live, /metrics", healthAddress, probeMux, tlsConfig)
+	case o.HealthSocket == "":
+		probeServer, err = listenHTTP("/health, /live", healthAddress, probeMux, nil)
+	default:
+		probeServer, err = o.listenHealthSocket(probeMux)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if o.HealthSocket == "" && o.MetricsPort == "" {
+		return []*httpServer{probeServer}, nil
+	}
+
+	metricsAddress := healthAddress
+	if o.MetricsPort != "" {
+		metricsAddress = net.JoinHostPort(o.HealthBindAddress, o.MetricsPort)
+	}
+
+	metricsMux := &http.ServeMux{}
+	metricsMux.HandleFunc("/metrics", metricsHandler)
+
+	metricsServer, err := listenHTTP("/metrics", metricsAddress, metricsMux, tlsConfig)
+	if err != nil {
+		probeServer.listener.Close()
+
+		return nil, err
+	}
+
+	return []*httpServer{probeServer, metricsServer}, nil
+}
+
+// metricsHandler returns the handler of /metrics requiring the configured bearer token or client certificate.
+func (o *Options) metricsHandler() http.HandlerFunc {
+	handler := promhttp.HandlerFor(metrics.RegisterPrometheusMetrics(), promhttp.HandlerOpts{}).ServeHTTP
+
+	if o.MetricsBearerTokenFile != "" {
+		handler = customHTTP.BearerTokenMiddleware(o.MetricsBearerTokenFile, handler)
+	}
+
+	if o.MetricsTLSClientCAFile != "" {
+		handler = customHTTP.ClientCertMiddleware(handler)
+	}
+
+	return customHTTP.LoggingMiddleware(handler)
+}
+
+// metricsTLSConfig returns the TLS config of the listener serving /metrics, nil if TLS is disabled.
+func (o *Options) metricsTLSConfig() (*tls.Config, error) {
+	if o.MetricsTLSCertFile == "" {
+		return nil, nil //nolint: nilnil
+	}
+
+	keyPair, err := certs.NewKeyPair(o.MetricsTLSCertFile, o.MetricsTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metrics tls key pair: %w", err)
+	}
+
+	var clientCAs *certs.CertPool
+
+	if o.MetricsTLSClientCAFile != "" {
+		clientCAs, err = certs.NewCertPool(o.MetricsTLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load metrics tls client ca: %w", err)
+		}
+	}
+
+	return certs.HTTPServerConfig(keyPair, clientCAs), nil
+}
+
+func (o *Options) listenHealthSocket(mux *http.ServeMux) (*httpServer, error) {
+	// already validated
+	s, _ := socket.NewSocket(o.HealthSocket)
+
+	listener, err := s.Listen(o.ForceSocketOverwrite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on health socket %s: %w", s.Path, err)
+	}
+
+	zap.L().Info("Exposing /health, /live", zap.String("socket", s.Path))
+
+	return newHTTPServer(listener, mux), nil
+}
+
+func listenHTTP(endpoints, address string, mux *http.ServeMux, tlsConfig *tls.Config) (*httpServer, error) {
+	listenConfig := net.ListenConfig{}
+
+	listener, err := listenConfig.Listen(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	zap.L().Info("Exposing "+endpoints, zap.String("address", address), zap.Bool("tls", tlsConfig != nil))
+
+	return newHTTPServer(listener, mux), nil
+}
+
+func newHTTPServer(listener net.Listener, handler http.Handler) *httpServer {
+	return &httpServer{
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+		listener: listener,
+	}
+}
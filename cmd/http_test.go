@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// serveHTTP serves the servers until the test ends and returns their addresses.
+func serveHTTP(t *testing.T, servers []*httpServer) []string {
+	t.Helper()
+
+	addresses := make([]string, 0, len(servers))
+
+	for _, s := range servers {
+		go func() { _ = s.server.Serve(s.listener) }()
+
+		t.Cleanup(func() { _ = s.server.Close() })
+
+		addresses = append(addresses, s.listener.Addr().String())
+	}
+
+	return addresses
+}
+
+func httpStatus(t *testing.T, client *http.Client, url, token string) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	return resp.StatusCode
+}
+
+func TestHTTPServersShared(t *testing.T) {
+	opts := &Options{HealthBindAddress: "127.0.0.1", HealthPort: "0"}
+
+	servers, err := opts.httpServers(nil)
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+
+	addr := serveHTTP(t, servers)[0]
+
+	for _, path := range []string{"/health", "/live", "/metrics"} {
+		require.Equal(t, http.StatusOK, httpStatus(t, http.DefaultClient, "http://"+addr+path, ""), path)
+	}
+}
+
+func TestHTTPServersMetricsPort(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0o600))
+
+	opts := &Options{HealthBindAddress: "127.0.0.1", HealthPort: "0", MetricsPort: "0", MetricsBearerTokenFile: tokenFile}
+
+	servers, err := opts.httpServers(nil)
+	require.NoError(t, err)
+	require.Len(t, servers, 2)
+
+	addrs := serveHTTP(t, servers)
+
+	// probes need no token and metrics are not served on the health port
+	require.Equal(t, http.StatusOK, httpStatus(t, http.DefaultClient, "http://"+addrs[0]+"/health", ""))
+	require.Equal(t, http.StatusNotFound, httpStatus(t, http.DefaultClient, "http://"+addrs[0]+"/metrics", "secret"))
+
+	require.Equal(t, http.StatusUnauthorized, httpStatus(t, http.DefaultClient, "http://"+addrs[1]+"/metrics", ""))
+	require.Equal(t, http.StatusUnauthorized, httpStatus(t, http.DefaultClient, "http://"+addrs[1]+"/metrics", "wrong"))
+	require.Equal(t, http.StatusOK, httpStatus(t, http.DefaultClient, "http://"+addrs[1]+"/metrics", "secret"))
+}
+
+func TestHTTPServersHealthSocketAndMetricsTLS(t *testing.T) {
+	testCerts, err := testutils.GenerateTestCerts()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+
+		return path
+	}
+
+	// unix socket paths are limited in length
+	socketDir, err := os.MkdirTemp("", "health")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+
+	socketPath := filepath.Join(socketDir, "health.sock")
+
+	opts := &Options{
+		HealthBindAddress:      "127.0.0.1",
+		HealthPort:             "0",
+		HealthSocket:           "unix://" + socketPath,
+		MetricsTLSCertFile:     write("server.crt", testCerts.ServerCertPEM),
+		MetricsTLSKeyFile:      write("server.key", testCerts.ServerKeyPEM),
+		MetricsTLSClientCAFile: write("ca.crt", testCerts.CACertPEM),
+	}
+	require.NoError(t, opts.validateHTTPFlags())
+
+	servers, err := opts.httpServers(nil)
+	require.NoError(t, err)
+	require.Len(t, servers, 2)
+
+	addrs := serveHTTP(t, servers)
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	require.Equal(t, http.StatusOK, httpStatus(t, unixClient, "http://health/live", ""))
+
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(testCerts.CACertPEM))
+
+	tlsClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      rootCAs,
+			Certificates: certs,
+		}}}
+	}
+
+	require.Equal(t, http.StatusUnauthorized, httpStatus(t, tlsClient(), "https://"+addrs[1]+"/metrics", ""))
+
+	clientCert, err := tls.X509KeyPair(testCerts.ClientCertPEM, testCerts.ClientKeyPEM)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, httpStatus(t, tlsClient(clientCert), "https://"+addrs[1]+"/metrics", ""))
+}
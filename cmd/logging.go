@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func (o *Options) validateLoggingFlags() error {
+	// empty defaults to json
+	if o.LogEncoding != "" && !slices.Contains([]string{logging.EncodingJSON, logging.EncodingConsole, logging.EncodingKlog}, o.LogEncoding) {
+		return errors.New("invalid log encoding. Supported: json, console, klog")
+	}
+
+	if o.LogSamplingInitial < 0 || o.LogSamplingThereafter < 0 {
+		return errors.New("log sampling values must not be negative")
+	}
+
+	if o.LogFileMaxSizeMB < 0 || o.LogFileMaxBackups < 0 {
+		return errors.New("log file max size and max backups must not be negative")
+	}
+
+	_, err := logging.ParseComponentLevels(o.LogLevels)
+	if err != nil {
+		return fmt.Errorf("invalid log levels: %w", err)
+	}
+
+	return nil
+}
+
+// loggingConfig returns the logging configuration, -debug sets the default level to debug.
+func (o *Options) loggingConfig() logging.Config {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	if o.Debug {
+		level.SetLevel(zapcore.DebugLevel)
+	}
+
+	// already validated
+	componentLevels, _ := logging.ParseComponentLevels(o.LogLevels)
+
+	return logging.Config{
+		Level:              level,
+		ComponentLevels:    componentLevels,
+		Encoding:           o.LogEncoding,
+		Sampling:           o.LogSampling,
+		SamplingInitial:    o.LogSamplingInitial,
+		SamplingThereafter: o.LogSamplingThereafter,
+		File:               o.LogFile,
+		FileMaxSizeMB:      o.LogFileMaxSizeMB,
+		FileMaxBackups:     o.LogFileMaxBackups,
+	}
+}
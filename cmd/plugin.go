@@ -14,15 +14,15 @@ import (
 	"syscall"
 	"time"
 
-	customHTTP "github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/http"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/certs"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
-	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/plugin"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/probes"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/socket"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/tracing"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/utils"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -30,6 +30,9 @@ import (
 )
 
 const (
+	// envPrefix is the prefix of the environment variables of the options.
+	envPrefix = "VAULT_KMS_"
+
 	shutdownTimeout      = 3 * time.Second
 	certAuthMethod       = "cert"
 	jwtAuthMethod        = "jwt"
@@ -40,28 +43,60 @@ const (
 type Options struct {
 	Socket               string `env:"SOCKET"                 envDefault:"unix:///opt/kms/vaultkms.socket"`
 	ForceSocketOverwrite bool   `env:"FORCE_SOCKET_OVERWRITE"`
+	ProvidersConfig      string `env:"PROVIDERS_CONFIG"`
+	SocketMode           string `env:"SOCKET_MODE"            envDefault:"0600"`
+	SocketOwner          string `env:"SOCKET_OWNER"`
+	SocketGroup          string `env:"SOCKET_GROUP"`
+
+	// socket peer credentials allow list
+	SocketAllowedUIDs        string `env:"SOCKET_ALLOWED_UIDS"`
+	SocketAllowedGIDs        string `env:"SOCKET_ALLOWED_GIDS"`
+	SocketAllowedExecutables string `env:"SOCKET_ALLOWED_EXECUTABLES"`
 
 	Debug bool `env:"DEBUG"`
 
+	// logging
+	LogEncoding           string `env:"LOG_ENCODING"            envDefault:"json"`
+	LogLevels             string `env:"LOG_LEVELS"`
+	LogSampling           bool   `env:"LOG_SAMPLING"            envDefault:"true"`
+	LogSamplingInitial    int    `env:"LOG_SAMPLING_INITIAL"    envDefault:"100"`
+	LogSamplingThereafter int    `env:"LOG_SAMPLING_THEREAFTER" envDefault:"100"`
+	LogFile               string `env:"LOG_FILE"`
+	LogFileMaxSizeMB      int    `env:"LOG_FILE_MAX_SIZE_MB"    envDefault:"100"`
+	LogFileMaxBackups     int    `env:"LOG_FILE_MAX_BACKUPS"    envDefault:"5"`
+
 	// vault server
 	VaultAddress   string `env:"VAULT_ADDR"`
 	VaultNamespace string `env:"VAULT_NAMESPACE"`
 	VaultCACert    string `env:"VAULT_CACERT"`
 
+	// vault tls
+	VaultClientCert      string `env:"VAULT_CLIENT_CERT"`
+	VaultClientKey       string `env:"VAULT_CLIENT_KEY"`
+	VaultTLSServerName   string `env:"VAULT_TLS_SERVER_NAME"`
+	VaultTLSMinVersion   string `env:"VAULT_TLS_MIN_VERSION"   envDefault:"1.2"`
+	VaultTLSCipherSuites string `env:"VAULT_TLS_CIPHER_SUITES"`
+
+	VaultHealthInterval string  `env:"VAULT_HEALTH_INTERVAL" envDefault:"10s"`
+	VaultRateLimit      float64 `env:"VAULT_RATE_LIMIT"      envDefault:"0"`
+
+	// vault request metrics
+	VaultRequestDurationBuckets string `env:"VAULT_REQUEST_DURATION_BUCKETS"`
+
 	// auth
 	AuthMethod string `env:"AUTH_METHOD"`
 
 	// token auth
-	Token string `env:"TOKEN"`
+	Token string `env:"TOKEN" redact:"true"`
 
 	// approle auth
 	AppRoleRoleID       string `env:"APPROLE_ROLE_ID"`
-	AppRoleRoleSecretID string `env:"APPROLE_SECRET_ID"`
+	AppRoleRoleSecretID string `env:"APPROLE_SECRET_ID" redact:"true"`
 	AppRoleMount        string `env:"APPROLE_MOUNT"     envDefault:"approle"`
 
 	// userpass auth
 	UserPassUsername string `env:"USERPASS_USERNAME"`
-	UserPassPassword string `env:"USERPASS_PASSWORD"`
+	UserPassPassword string `env:"USERPASS_PASSWORD" redact:"true"`
 	UserPassMount    string `env:"USERPASS_MOUNT"    envDefault:"userpass"`
 
 	// cert auth (Vault TLS Certificate auth method)
@@ -88,8 +123,63 @@ type Options struct {
 	TransitKey   string `env:"TRANSIT_KEY"   envDefault:"kms"`
 	TransitMount string `env:"TRANSIT_MOUNT" envDefault:"transit"`
 
-	// healthz check
-	HealthPort string `env:"HEALTH_PORT" envDefault:"8080"`
+	// transit batching
+	BatchWindow  string `env:"BATCH_WINDOW"   envDefault:"0s"`
+	BatchMaxSize int    `env:"BATCH_MAX_SIZE" envDefault:"128"`
+
+	// grpc server
+	GRPCDefaultTimeout string `env:"GRPC_DEFAULT_TIMEOUT"  envDefault:"10s"`
+	GRPCMaxTimeout     string `env:"GRPC_MAX_TIMEOUT"      envDefault:"30s"`
+	GRPCMaxMessageSize int    `env:"GRPC_MAX_MESSAGE_SIZE" envDefault:"4194304"`
+	GRPCHealthInterval string `env:"GRPC_HEALTH_INTERVAL"  envDefault:"10s"`
+	GRPCReflection     bool   `env:"GRPC_REFLECTION"       envDefault:"false"`
+
+	// grpc mutual tls, required for tcp:// sockets
+	GRPCTLSCertFile          string `env:"GRPC_TLS_CERT_FILE"`
+	GRPCTLSKeyFile           string `env:"GRPC_TLS_KEY_FILE"`
+	GRPCTLSClientCAFile      string `env:"GRPC_TLS_CLIENT_CA_FILE"`
+	GRPCTLSAllowedClientSANs string `env:"GRPC_TLS_ALLOWED_CLIENT_SANS"`
+
+	// grpc concurrency limits
+	GRPCMaxInFlight       int `env:"GRPC_MAX_IN_FLIGHT"        envDefault:"0"`
+	GRPCMaxQueued         int `env:"GRPC_MAX_QUEUED"           envDefault:"128"`
+	GRPCStatusMaxInFlight int `env:"GRPC_STATUS_MAX_IN_FLIGHT" envDefault:"0"`
+
+	// health and metrics server
+	HealthPort             string `env:"HEALTH_PORT"                envDefault:"8080"`
+	HealthBindAddress      string `env:"HEALTH_BIND_ADDRESS"`
+	HealthSocket           string `env:"HEALTH_SOCKET"`
+	MetricsPort            string `env:"METRICS_PORT"`
+	MetricsTLSCertFile     string `env:"METRICS_TLS_CERT_FILE"`
+	MetricsTLSKeyFile      string `env:"METRICS_TLS_KEY_FILE"`
+	MetricsTLSClientCAFile string `env:"METRICS_TLS_CLIENT_CA_FILE"`
+	MetricsBearerTokenFile string `env:"METRICS_BEARER_TOKEN_FILE"`
+
+	// admin endpoints
+	AdminAddress string `env:"ADMIN_ADDRESS"`
+
+	// memory hardening
+	HardenedMemory bool `env:"HARDENED_MEMORY" envDefault:"false"`
+	Mlock          bool `env:"MLOCK"           envDefault:"false"`
+
+	// tracing
+	TracingEndpoint    string  `env:"TRACING_ENDPOINT"`
+	TracingInsecure    bool    `env:"TRACING_INSECURE"     envDefault:"false"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// audit log
+	AuditSink           string `env:"AUDIT_SINK"`
+	AuditFile           string `env:"AUDIT_FILE"`
+	AuditFileMaxSizeMB  int    `env:"AUDIT_FILE_MAX_SIZE_MB"  envDefault:"100"`
+	AuditFileMaxBackups int    `env:"AUDIT_FILE_MAX_BACKUPS"  envDefault:"5"`
+	AuditSyslogSocket   string `env:"AUDIT_SYSLOG_SOCKET"     envDefault:"/dev/log"`
+	AuditHMACKeyFile    string `env:"AUDIT_HMAC_KEY_FILE"`
+
+	// kubernetes events
+	EventsEnabled    bool   `env:"EVENTS_ENABLED"    envDefault:"false"`
+	EventsKubeconfig string `env:"EVENTS_KUBECONFIG"`
+	EventsObject     string `env:"EVENTS_OBJECT"`
+	EventsInterval   string `env:"EVENTS_INTERVAL"   envDefault:"5m"`
 
 	DisableV1 bool `env:"DISABLE_V1" envDefault:"false"`
 	DisableV2 bool `env:"DISABLE_V2" envDefault:"false"`
@@ -99,11 +189,11 @@ type Options struct {
 
 // NewPlugin instantiates the plugin.
 // nolint: funlen, cyclop, maintidx
-func NewPlugin(version string) error {
+func NewPlugin(version, commit string) error {
 	opts := &Options{}
 
 	// first parse any env vars
-	err := utils.ParseEnvs("VAULT_KMS_", opts)
+	err := utils.ParseEnvs(envPrefix, opts)
 	if err != nil {
 		return fmt.Errorf("error parsing env vars: %w", err)
 	}
@@ -113,12 +203,36 @@ func NewPlugin(version string) error {
 	flag.StringVar(&opts.Socket, "socket", opts.Socket, "Destination path of the socket (required)")
 	flag.BoolVar(&opts.ForceSocketOverwrite, "force-socket-overwrite", opts.ForceSocketOverwrite, "Force creation of the socket file."+
 		"Use with caution deletes whatever exists at -socket!")
+	flag.StringVar(&opts.ProvidersConfig, "providers-config", opts.ProvidersConfig, "Path to a file configuring multiple KMS providers, each served on its own socket")
+	flag.StringVar(&opts.SocketMode, "socket-mode", opts.SocketMode, "File mode of the socket in octal notation")
+	flag.StringVar(&opts.SocketOwner, "socket-owner", opts.SocketOwner, "Owner (name or UID) of the socket")
+	flag.StringVar(&opts.SocketGroup, "socket-group", opts.SocketGroup, "Group (name or GID) of the socket")
+	flag.StringVar(&opts.SocketAllowedUIDs, "socket-allowed-uids", opts.SocketAllowedUIDs, "Comma separated list of UIDs allowed to connect to the socket")
+	flag.StringVar(&opts.SocketAllowedGIDs, "socket-allowed-gids", opts.SocketAllowedGIDs, "Comma separated list of GIDs allowed to connect to the socket")
+	flag.StringVar(&opts.SocketAllowedExecutables, "socket-allowed-executables", opts.SocketAllowedExecutables,
+		"Comma separated list of executable paths allowed to connect to the socket")
 
 	flag.BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logs")
+	flag.StringVar(&opts.LogEncoding, "log-encoding", opts.LogEncoding, "Log encoding. Supported: json, console, klog")
+	flag.StringVar(&opts.LogLevels, "log-levels", opts.LogLevels, "Comma separated log levels of components, e.g. vault=debug,http=warn. Supported components: vault, plugin, probes, http, grpc, socket, certs, audit, admin, events")
+	flag.BoolVar(&opts.LogSampling, "log-sampling", opts.LogSampling, "Sample repeated log messages")
+	flag.IntVar(&opts.LogSamplingInitial, "log-sampling-initial", opts.LogSamplingInitial, "Number of identical log messages logged per second before sampling")
+	flag.IntVar(&opts.LogSamplingThereafter, "log-sampling-thereafter", opts.LogSamplingThereafter, "Log every nth identical log message per second once sampling")
+	flag.StringVar(&opts.LogFile, "log-file", opts.LogFile, "Path of the log file (empty logs to stdout)")
+	flag.IntVar(&opts.LogFileMaxSizeMB, "log-file-max-size-mb", opts.LogFileMaxSizeMB, "Size in megabytes at which -log-file is rotated")
+	flag.IntVar(&opts.LogFileMaxBackups, "log-file-max-backups", opts.LogFileMaxBackups, "Number of rotated log files to keep (0 keeps all)")
 
 	flag.StringVar(&opts.VaultAddress, "vault-address", opts.VaultAddress, "Vault API address (required)")
 	flag.StringVar(&opts.VaultNamespace, "vault-namespace", opts.VaultNamespace, "Vault Namespace (only when Vault Enterprise)")
-	flag.StringVar(&opts.VaultCACert, "vault-ca-cert", opts.VaultCACert, "Path to CA cert for verifying Vault's TLS certificate")
+	flag.StringVar(&opts.VaultCACert, "vault-ca-cert", opts.VaultCACert, "Path to CA cert file or directory for verifying Vault's TLS certificate")
+	flag.StringVar(&opts.VaultClientCert, "vault-client-cert", opts.VaultClientCert, "Path to a client certificate presented to Vault listeners requiring mutual TLS")
+	flag.StringVar(&opts.VaultClientKey, "vault-client-key", opts.VaultClientKey, "Path to the private key of -vault-client-cert")
+	flag.StringVar(&opts.VaultTLSServerName, "vault-tls-server-name", opts.VaultTLSServerName, "Server name used to verify Vault's TLS certificate and sent via SNI")
+	flag.StringVar(&opts.VaultTLSMinVersion, "vault-tls-min-version", opts.VaultTLSMinVersion, "Minimum TLS version of the Vault connection. Supported: 1.2, 1.3")
+	flag.StringVar(&opts.VaultTLSCipherSuites, "vault-tls-cipher-suites", opts.VaultTLSCipherSuites, "Comma separated list of TLS 1.2 cipher suites of the Vault connection (empty uses Go's defaults)")
+	flag.StringVar(&opts.VaultHealthInterval, "vault-health-interval", opts.VaultHealthInterval, "Interval to check Vault's seal and standby state (0s disables the check)")
+	flag.Float64Var(&opts.VaultRateLimit, "vault-rate-limit", opts.VaultRateLimit, "Maximum number of Vault requests per second (0 only throttles after Vault responded with 429)")
+	flag.StringVar(&opts.VaultRequestDurationBuckets, "vault-request-duration-buckets", opts.VaultRequestDurationBuckets, "Comma separated buckets in seconds of the Vault request duration histogram (empty uses buckets from 0.5ms to 10s)")
 
 	flag.StringVar(&opts.AuthMethod, "auth-method", opts.AuthMethod, "Auth Method. Supported: token, approle, userpass, cert, jwt")
 
@@ -151,17 +265,61 @@ func NewPlugin(version string) error {
 	flag.StringVar(&opts.JWTSpiffeAudience, "jwt-spiffe-audience", opts.JWTSpiffeAudience, "JWT-SVID audience (when JWT token source is spiffe)")
 	flag.StringVar(&opts.JWTSpiffeID, "jwt-spiffe-id", opts.JWTSpiffeID, "Exact SPIFFE ID to request (when JWT token source is spiffe)")
 
-	flag.StringVar(&opts.TokenRefreshInterval, "token-refresh-interval", opts.TokenRefreshInterval, "Interval to check for a token renewal")
+	flag.StringVar(&opts.TokenRefreshInterval, "token-refresh-interval", opts.TokenRefreshInterval, "Interval to verify the token, renewals are scheduled relative to the token TTL")
 	flag.IntVar(&opts.TokenRenewalSeconds, "token-renewal", opts.TokenRenewalSeconds, "The number of seconds to renew the token")
 
 	flag.StringVar(&opts.TransitMount, "transit-mount", opts.TransitMount, "Vault Transit mount name")
 	flag.StringVar(&opts.TransitKey, "transit-key", opts.TransitKey, "Vault Transit key name")
 
+	flag.StringVar(&opts.BatchWindow, "batch-window", opts.BatchWindow, "Window to gather concurrent transit requests into a single batch call (0s disables batching)")
+	flag.IntVar(&opts.BatchMaxSize, "batch-max-size", opts.BatchMaxSize, "Maximum number of items in a single transit batch call")
+
+	flag.StringVar(&opts.GRPCDefaultTimeout, "grpc-default-timeout", opts.GRPCDefaultTimeout, "Timeout for KMS requests without a deadline (0s disables the default timeout)")
+	flag.StringVar(&opts.GRPCMaxTimeout, "grpc-max-timeout", opts.GRPCMaxTimeout, "Maximum timeout for KMS requests (0s disables the limit)")
+	flag.IntVar(&opts.GRPCMaxMessageSize, "grpc-max-message-size", opts.GRPCMaxMessageSize, "Maximum size in bytes of received and sent KMS messages")
+	flag.StringVar(&opts.GRPCHealthInterval, "grpc-health-interval", opts.GRPCHealthInterval, "Interval to update the status of the grpc.health.v1 service (0s only checks once at startup)")
+	flag.BoolVar(&opts.GRPCReflection, "grpc-reflection", opts.GRPCReflection, "Enable gRPC server reflection on the socket")
+	flag.StringVar(&opts.GRPCTLSCertFile, "grpc-tls-cert-file", opts.GRPCTLSCertFile, "Server certificate for tcp:// sockets")
+	flag.StringVar(&opts.GRPCTLSKeyFile, "grpc-tls-key-file", opts.GRPCTLSKeyFile, "Server private key for tcp:// sockets")
+	flag.StringVar(&opts.GRPCTLSClientCAFile, "grpc-tls-client-ca-file", opts.GRPCTLSClientCAFile, "CA certificates (file or directory) verifying client certificates on tcp:// sockets")
+	flag.StringVar(&opts.GRPCTLSAllowedClientSANs, "grpc-tls-allowed-client-sans", opts.GRPCTLSAllowedClientSANs,
+		"Comma separated list of subject alternative names of which a client certificate must contain one (empty allows all clients signed by the CA)")
+	flag.IntVar(&opts.GRPCMaxInFlight, "grpc-max-in-flight", opts.GRPCMaxInFlight, "Maximum number of concurrent Encrypt and Decrypt requests (0 disables the limit)")
+	flag.IntVar(&opts.GRPCMaxQueued, "grpc-max-queued", opts.GRPCMaxQueued, "Maximum number of requests waiting for a free slot before requests are rejected")
+	flag.IntVar(&opts.GRPCStatusMaxInFlight, "grpc-status-max-in-flight", opts.GRPCStatusMaxInFlight, "Maximum number of concurrent Status and Version requests (0 disables the limit)")
+
 	flag.StringVar(&opts.HealthPort, "health-port", opts.HealthPort, "Health Check Port")
+	flag.StringVar(&opts.HealthBindAddress, "health-bind-address", opts.HealthBindAddress, "Address the health and metrics ports bind to (empty binds to all interfaces)")
+	flag.StringVar(&opts.HealthSocket, "health-socket", opts.HealthSocket, "Serve /health and /live on this unix socket (unix:///path) instead of the health port")
+	flag.StringVar(&opts.MetricsPort, "metrics-port", opts.MetricsPort, "Serve /metrics on its own port (empty serves /metrics on the health port)")
+	flag.StringVar(&opts.MetricsTLSCertFile, "metrics-tls-cert-file", opts.MetricsTLSCertFile, "Server certificate of the listener serving /metrics")
+	flag.StringVar(&opts.MetricsTLSKeyFile, "metrics-tls-key-file", opts.MetricsTLSKeyFile, "Server key of the listener serving /metrics")
+	flag.StringVar(&opts.MetricsTLSClientCAFile, "metrics-tls-client-ca-file", opts.MetricsTLSClientCAFile, "CA file or directory verifying client certificates required for /metrics")
+	flag.StringVar(&opts.AdminAddress, "admin-address", opts.AdminAddress, "Serve pprof, log level, config and token endpoints on this loopback address (tcp://127.0.0.1:port) or unix socket (unix:///path), empty disables them")
+	flag.StringVar(&opts.MetricsBearerTokenFile, "metrics-bearer-token-file", opts.MetricsBearerTokenFile, "File containing the bearer token required for /metrics")
 
 	flag.BoolVar(&opts.DisableV1, "disable-v1", opts.DisableV1, "disable the v1 kms plugin")
 	flag.BoolVar(&opts.DisableV2, "disable-v2", opts.DisableV2, "disable the v2 kms plugin")
 
+	flag.BoolVar(&opts.HardenedMemory, "hardened-memory", opts.HardenedMemory, "Wipe plaintext DEKs after use and disable core dumps")
+	flag.BoolVar(&opts.Mlock, "mlock", opts.Mlock, "Lock the plugins memory into RAM to prevent key material from being swapped (requires CAP_IPC_LOCK)")
+
+	flag.StringVar(&opts.TracingEndpoint, "tracing-endpoint", opts.TracingEndpoint, "host:port of an OTLP gRPC receiver to export traces to (empty disables tracing)")
+	flag.BoolVar(&opts.TracingInsecure, "tracing-insecure", opts.TracingInsecure, "Disable TLS for the connection to the OTLP receiver")
+	flag.Float64Var(&opts.TracingSampleRatio, "tracing-sample-ratio", opts.TracingSampleRatio, "Fraction of traces to sample, between 0 and 1")
+
+	flag.StringVar(&opts.AuditSink, "audit-sink", opts.AuditSink, "Write an audit entry for every KMS operation. Supported: file, stdout, syslog (empty disables the audit log)")
+	flag.StringVar(&opts.AuditFile, "audit-file", opts.AuditFile, "Path of the audit log (when file audit sink)")
+	flag.IntVar(&opts.AuditFileMaxSizeMB, "audit-file-max-size-mb", opts.AuditFileMaxSizeMB, "Size in megabytes after which the audit log is rotated (0 disables rotation)")
+	flag.IntVar(&opts.AuditFileMaxBackups, "audit-file-max-backups", opts.AuditFileMaxBackups, "Number of rotated audit logs to keep")
+	flag.StringVar(&opts.AuditSyslogSocket, "audit-syslog-socket", opts.AuditSyslogSocket, "Unix socket of the syslog daemon (when syslog audit sink)")
+	flag.StringVar(&opts.AuditHMACKeyFile, "audit-hmac-key-file", opts.AuditHMACKeyFile, "Path to the key used to chain audit entries with HMAC-SHA256 (required when audit log enabled)")
+
+	flag.BoolVar(&opts.EventsEnabled, "events-enabled", opts.EventsEnabled, "Report authentication, token, transit key and Vault health problems as Kubernetes Events")
+	flag.StringVar(&opts.EventsKubeconfig, "events-kubeconfig", opts.EventsKubeconfig, "Path to the kubeconfig used to create events (empty uses the in-cluster config)")
+	flag.StringVar(&opts.EventsObject, "events-object", opts.EventsObject, "Object the events are reported for, <kind>/<namespace>/<name> or <kind>/<name> (empty uses the static pod of the plugin)")
+	flag.StringVar(&opts.EventsInterval, "events-interval", opts.EventsInterval, "Minimum interval between events with the same reason")
+
 	flag.BoolVar(&opts.Version, "version", opts.Version, "prints out the plugins version")
 
 	err = flag.Parse(os.Args[1:])
@@ -170,7 +328,7 @@ func NewPlugin(version string) error {
 	}
 
 	if opts.Version {
-		fmt.Fprintf(os.Stdout, "vault-kubernetes-kms v%s\n", version)
+		fmt.Fprintf(os.Stdout, "vault-kubernetes-kms v%s (%s)\n", version, commit)
 
 		return nil
 	}
@@ -180,19 +338,36 @@ func NewPlugin(version string) error {
 		return fmt.Errorf("error validating args: %w", err)
 	}
 
-	logLevel := zapcore.InfoLevel
+	metrics.SetBuildInfo(version, commit)
 
-	if opts.Debug {
-		logLevel = zapcore.DebugLevel
+	// already validated
+	if buckets, _ := metrics.ParseBuckets(opts.VaultRequestDurationBuckets); len(buckets) > 0 {
+		metrics.SetVaultRequestsBuckets(buckets)
 	}
 
-	l, err := logging.NewStandardLogger(logLevel)
+	logConfig := opts.loggingConfig()
+
+	l, err := logging.New(logConfig)
 	if err != nil {
 		return fmt.Errorf("failed to configure logging: %w", err)
 	}
 
 	zap.ReplaceGlobals(l)
 
+	if opts.HardenedMemory {
+		err = secure.DisableCoreDumps()
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Mlock {
+		err = secure.LockMemory()
+		if err != nil {
+			return err
+		}
+	}
+
 	var (
 		authMethod   vault.Option
 		logFields    []zapcore.Field
@@ -200,31 +375,101 @@ func NewPlugin(version string) error {
 		ctx          = shutDownSignal(context.Background())
 	)
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:    opts.TracingEndpoint,
+		Insecure:    opts.TracingInsecure,
+		SampleRatio: opts.TracingSampleRatio,
+		Version:     version,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			zap.L().Warn("failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	logFields = append(logFields,
 		zap.String("auth-method", opts.AuthMethod),
 		zap.String("socket", opts.Socket),
+		zap.String("providers-config", opts.ProvidersConfig),
+		zap.String("socket-mode", opts.SocketMode),
+		zap.String("socket-owner", opts.SocketOwner),
+		zap.String("socket-group", opts.SocketGroup),
+		zap.String("socket-allowed-uids", opts.SocketAllowedUIDs),
+		zap.String("socket-allowed-gids", opts.SocketAllowedGIDs),
+		zap.String("socket-allowed-executables", opts.SocketAllowedExecutables),
 		zap.Bool("debug", opts.Debug),
+		zap.String("log-encoding", opts.LogEncoding),
+		zap.String("log-levels", opts.LogLevels),
+		zap.Bool("log-sampling", opts.LogSampling),
+		zap.Int("log-sampling-initial", opts.LogSamplingInitial),
+		zap.Int("log-sampling-thereafter", opts.LogSamplingThereafter),
+		zap.String("log-file", opts.LogFile),
+		zap.Int("log-file-max-size-mb", opts.LogFileMaxSizeMB),
+		zap.Int("log-file-max-backups", opts.LogFileMaxBackups),
 		zap.String("vault-address", opts.VaultAddress),
 		zap.String("vault-namespace", opts.VaultNamespace),
+		zap.String("vault-health-interval", opts.VaultHealthInterval),
+		zap.Float64("vault-rate-limit", opts.VaultRateLimit),
+		zap.String("vault-request-duration-buckets", opts.VaultRequestDurationBuckets),
 		zap.String("transit-engine", opts.TransitMount),
 		zap.String("transit-key", opts.TransitKey),
+		zap.String("batch-window", opts.BatchWindow),
+		zap.Int("batch-max-size", opts.BatchMaxSize),
+		zap.String("grpc-default-timeout", opts.GRPCDefaultTimeout),
+		zap.String("grpc-max-timeout", opts.GRPCMaxTimeout),
+		zap.Int("grpc-max-message-size", opts.GRPCMaxMessageSize),
+		zap.String("grpc-health-interval", opts.GRPCHealthInterval),
+		zap.Bool("grpc-reflection", opts.GRPCReflection),
+		zap.String("grpc-tls-cert-file", opts.GRPCTLSCertFile),
+		zap.String("grpc-tls-key-file", opts.GRPCTLSKeyFile),
+		zap.String("grpc-tls-client-ca-file", opts.GRPCTLSClientCAFile),
+		zap.String("grpc-tls-allowed-client-sans", opts.GRPCTLSAllowedClientSANs),
+		zap.Int("grpc-max-in-flight", opts.GRPCMaxInFlight),
+		zap.Int("grpc-max-queued", opts.GRPCMaxQueued),
+		zap.Int("grpc-status-max-in-flight", opts.GRPCStatusMaxInFlight),
 		zap.String("health-port", opts.HealthPort),
+		zap.String("health-bind-address", opts.HealthBindAddress),
+		zap.String("health-socket", opts.HealthSocket),
+		zap.String("metrics-port", opts.MetricsPort),
+		zap.String("metrics-tls-cert-file", opts.MetricsTLSCertFile),
+		zap.String("metrics-tls-key-file", opts.MetricsTLSKeyFile),
+		zap.String("metrics-tls-client-ca-file", opts.MetricsTLSClientCAFile),
+		zap.String("metrics-bearer-token-file", opts.MetricsBearerTokenFile),
+		zap.String("admin-address", opts.AdminAddress),
 		zap.String("token-refresh-interval", opts.TokenRefreshInterval),
 		zap.Int("token-renewal-seconds", opts.TokenRenewalSeconds),
 		zap.Bool("disable-v1", opts.DisableV1),
 		zap.Bool("disable-v2", opts.DisableV2),
+		zap.Bool("hardened-memory", opts.HardenedMemory),
+		zap.Bool("mlock", opts.Mlock),
+		zap.String("tracing-endpoint", opts.TracingEndpoint),
+		zap.Bool("tracing-insecure", opts.TracingInsecure),
+		zap.Float64("tracing-sample-ratio", opts.TracingSampleRatio),
+		zap.String("audit-sink", opts.AuditSink),
+		zap.String("audit-file", opts.AuditFile),
+		zap.Int("audit-file-max-size-mb", opts.AuditFileMaxSizeMB),
+		zap.Int("audit-file-max-backups", opts.AuditFileMaxBackups),
+		zap.String("audit-syslog-socket", opts.AuditSyslogSocket),
+		zap.String("audit-hmac-key-file", opts.AuditHMACKeyFile),
+		zap.Bool("events-enabled", opts.EventsEnabled),
+		zap.String("events-kubeconfig", opts.EventsKubeconfig),
+		zap.String("events-object", opts.EventsObject),
+		zap.String("events-interval", opts.EventsInterval),
 	)
 
-	// Propagate --vault-ca-cert to the VAULT_CACERT env var so that api.DefaultConfig()
-	// picks it up when building the main Vault client's TLS transport.
-	if opts.VaultCACert != "" {
-		err = os.Setenv("VAULT_CACERT", opts.VaultCACert)
-		if err != nil {
-			return fmt.Errorf("setting VAULT_CACERT: %w", err)
-		}
-
-		logFields = append(logFields, zap.String("vault-ca-cert", opts.VaultCACert))
-	}
+	logFields = append(logFields,
+		zap.String("vault-ca-cert", opts.VaultCACert),
+		zap.String("vault-client-cert", opts.VaultClientCert),
+		zap.String("vault-tls-server-name", opts.VaultTLSServerName),
+		zap.String("vault-tls-min-version", opts.VaultTLSMinVersion),
+		zap.String("vault-tls-cipher-suites", opts.VaultTLSCipherSuites))
 
 	switch strings.ToLower(opts.AuthMethod) {
 	case "token":
@@ -242,15 +487,9 @@ func NewPlugin(version string) error {
 	case certAuthMethod:
 		certFile, certKey := opts.CertFile, opts.CertKey
 
+		// a combined PEM file contains both the certificate and the key
 		if opts.CertPEM != "" {
-			var cleanup func()
-
-			certFile, certKey, cleanup, err = vault.ParseCombinedPEMFile(opts.CertPEM)
-			if err != nil {
-				return fmt.Errorf("parsing combined PEM file: %w", err)
-			}
-
-			defer cleanup()
+			certFile, certKey = opts.CertPEM, ""
 		}
 
 		authMethod = vault.WithCertAuth(opts.CertAuthMount, opts.CertAuthRole, certFile, certKey, opts.VaultCACert)
@@ -266,109 +505,141 @@ func NewPlugin(version string) error {
 
 	zap.L().Info("starting kms plugin", logFields...)
 
+	batchWindow, _ := time.ParseDuration(opts.BatchWindow)
+
+	if opts.HardenedMemory && batchWindow > 0 {
+		zap.L().Warn("Transit batching is not used with hardened memory, ignoring batch window")
+	}
+
+	eventReporter, err := opts.eventReporter()
+	if err != nil {
+		return err
+	}
+
+	if eventReporter != nil {
+		// wait for pending events on shutdown
+		defer eventReporter.Wait()
+	}
+
 	vc, err := vault.NewClient(
 		vault.WithVaultAddress(opts.VaultAddress),
 		vault.WithVaultNamespace(opts.VaultNamespace),
+		vault.WithTLS(opts.vaultTLSOptions()),
 		vault.WithTransit(opts.TransitMount, opts.TransitKey),
 		vault.WithTokenRenewalSeconds(opts.TokenRenewalSeconds),
+		vault.WithBatching(batchWindow, opts.BatchMaxSize),
+		vault.WithRateLimit(opts.VaultRateLimit),
+		vault.WithHardenedMemory(opts.HardenedMemory),
+		vault.WithEvents(eventReporter),
 		authMethod,
 	)
 	if err != nil {
+		// Fatal exits without running deferred functions
+		eventReporter.Wait()
+
 		zap.L().Fatal("Failed to create vault client", zap.Error(err))
 	}
 
 	zap.L().Info("Successfully authenticated to vault")
 
-	go func() {
-		zap.L().Info("Starting token refresher",
-			zap.String("interval", opts.TokenRefreshInterval),
-			zap.Int("renewal-seconds", opts.TokenRenewalSeconds),
-		)
+	tokenRefreshInterval, _ := time.ParseDuration(opts.TokenRefreshInterval)
+	tokenManager := vault.NewTokenManager(vc, tokenRefreshInterval)
 
-		t, _ := time.ParseDuration(opts.TokenRefreshInterval)
+	zap.L().Info("Starting token manager",
+		zap.String("interval", opts.TokenRefreshInterval),
+		zap.Int("renewal-seconds", opts.TokenRenewalSeconds),
+	)
 
-		vc.LeaseRefresher(ctx, t)
-	}()
+	go tokenManager.Run(ctx)
 
-	s, err := socket.NewSocket(opts.Socket)
-	if err != nil {
-		zap.L().Fatal("Cannot create socket", zap.Error(err))
-	}
+	healthChecks = append(healthChecks, tokenManager)
 
-	zap.L().Info("Successfully created unix socket", zap.String("socket", s.Path))
+	if healthInterval, _ := time.ParseDuration(opts.VaultHealthInterval); healthInterval > 0 {
+		zap.L().Info("Starting vault server state watcher", zap.String("interval", opts.VaultHealthInterval))
 
-	listener, err := s.Listen(opts.ForceSocketOverwrite)
-	if err != nil {
-		zap.L().Fatal("failed to listen on socket: Use -force-socket-overwrite (VAULT_KUBERNETES_KMS_FORCE_SOCKET_OVERWRITE)",
-			zap.String("socket", opts.Socket),
-			zap.Any("error", err))
+		go vc.ServerStateWatcher(ctx, healthInterval)
+
+		healthChecks = append(healthChecks, vc)
 	}
 
-	// clean up socket
-	defer listener.Close()
+	auditLogger, err := opts.auditLogger()
+	if err != nil {
+		return err
+	}
 
-	zap.L().Info("Listening for connection")
+	if auditLogger != nil {
+		defer auditLogger.Close()
 
-	grpcServer := grpc.NewServer()
+		zap.L().Info("Writing audit log", zap.String("sink", opts.AuditSink))
+	}
 
-	if !opts.DisableV1 {
-		pluginV1 := plugin.NewPluginV1(vc)
-		pluginV1.Register(grpcServer)
+	providers, _ := opts.providers() // already validated
+	vaultChecks := slices.Clone(healthChecks)
+	grpcServers := make([]*grpc.Server, 0, len(providers))
 
-		healthChecks = append(healthChecks, pluginV1)
+	for _, p := range providers {
+		kms, err := opts.newProvider(ctx, p, vc, vaultChecks, auditLogger)
+		if err != nil {
+			zap.L().Fatal("failed to start kms provider: Use -force-socket-overwrite (VAULT_KMS_FORCE_SOCKET_OVERWRITE) to replace a socket in use",
+				zap.String("provider", p.Name),
+				zap.String("socket", p.Socket),
+				zap.Any("error", err))
+		}
 
-		zap.L().Info("Successfully registered kms plugin v1")
-	}
+		// clean up socket
+		defer kms.listener.Close()
 
-	if !opts.DisableV2 {
-		pluginV2 := plugin.NewPluginV2(vc)
-		pluginV2.Register(grpcServer)
-		healthChecks = append(healthChecks, pluginV2)
+		grpcServers = append(grpcServers, kms.server)
+		healthChecks = append(healthChecks, kms.probers...)
 
-		zap.L().Info("Successfully registered kms plugin v2")
+		go func() {
+			serveErr := kms.server.Serve(kms.listener)
+			if serveErr != nil && !errors.Is(serveErr, grpc.ErrServerStopped) && !errors.Is(serveErr, net.ErrClosed) {
+				zap.L().Fatal("Failed to start kms plugin", zap.String("provider", p.Name), zap.Error(serveErr))
+			}
+		}()
 	}
 
-	go func() {
-		serveErr := grpcServer.Serve(listener)
-		if serveErr != nil && !errors.Is(serveErr, grpc.ErrServerStopped) && !errors.Is(serveErr, net.ErrClosed) {
-			zap.L().Fatal("Failed to start kms plugin", zap.Error(serveErr))
-		}
-	}()
+	zap.L().Info("Successfully registered grpc health service", zap.String("interval", opts.GRPCHealthInterval))
 
-	mux := &http.ServeMux{}
-	mux.HandleFunc("/metrics", customHTTP.LoggingMiddleware(promhttp.HandlerFor(metrics.RegisterPrometheusMetrics(), promhttp.HandlerOpts{}).ServeHTTP))
-	mux.HandleFunc("/health", customHTTP.LoggingMiddleware(probes.HealthZ(healthChecks)))
-	mux.HandleFunc("/live", customHTTP.LoggingMiddleware(probes.HealthZ(healthChecks)))
+	httpServers, err := opts.httpServers(healthChecks)
+	if err != nil {
+		return err
+	}
 
-	//nolint: mnd
-	server := &http.Server{
-		Addr:              ":" + opts.HealthPort,
-		Handler:           mux,
-		ReadHeaderTimeout: 3 * time.Second,
+	adminServer, err := opts.adminServer(logConfig.Level, vc)
+	if err != nil {
+		return err
 	}
 
-	zap.L().Info("Exposing metrics under /metrics", zap.String("port", opts.HealthPort))
-	zap.L().Info("Exposing health check under /health", zap.String("port", opts.HealthPort))
-	zap.L().Info("Exposing live check under /live", zap.String("port", opts.HealthPort))
+	if adminServer != nil {
+		httpServers = append(httpServers, adminServer)
+	}
 
-	go func() {
-		serverErr := server.ListenAndServe()
-		if serverErr != nil && !errors.Is(serverErr, http.ErrServerClosed) {
-			zap.L().Fatal("Failed to start health check handlers", zap.Error(serverErr))
-		}
-	}()
+	for _, s := range httpServers {
+		go func() {
+			serverErr := s.server.Serve(s.listener)
+			if serverErr != nil && !errors.Is(serverErr, http.ErrServerClosed) {
+				zap.L().Fatal("Failed to start health check handlers", zap.Error(serverErr))
+			}
+		}()
+	}
 
 	<-ctx.Done()
 
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	err = server.Shutdown(ctx)
-	if err != nil {
-		return fmt.Errorf("error while shutting down server: %w", err)
+	for _, s := range httpServers {
+		err = s.server.Shutdown(ctx)
+		if err != nil {
+			return fmt.Errorf("error while shutting down server: %w", err)
+		}
 	}
 
-	grpcServer.GracefulStop()
+	for _, grpcServer := range grpcServers {
+		grpcServer.GracefulStop()
+	}
 
 	zap.L().Info("Exiting...")
 
@@ -406,7 +677,7 @@ func (o *Options) validateFlags() error {
 		return errors.New("cert auth requires either --cert-pem or both --cert-file and --cert-key")
 
 	// validate jwt auth
-	case o.DisableV1 && o.DisableV2:
+	case o.ProvidersConfig == "" && o.DisableV1 && o.DisableV2:
 		return errors.New("at least one kms plugin version must be enabled")
 	}
 
@@ -417,12 +688,143 @@ func (o *Options) validateFlags() error {
 		}
 	}
 
-	_, err := time.ParseDuration(o.TokenRefreshInterval)
+	tokenRefreshInterval, err := time.ParseDuration(o.TokenRefreshInterval)
 	if err != nil {
 		return fmt.Errorf("invalid token refresh interval: %w", err)
 	}
 
-	return nil
+	if tokenRefreshInterval <= 0 {
+		return errors.New("token refresh interval must be positive")
+	}
+
+	providers, err := o.providers()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range providers {
+		// already validated
+		s, _ := socket.NewSocket(p.Socket)
+
+		if s != nil && s.IsTCP() && (o.GRPCTLSCertFile == "" || o.GRPCTLSKeyFile == "" || o.GRPCTLSClientCAFile == "") {
+			return fmt.Errorf("tcp socket of provider %s requires grpc tls cert file, key file and client ca file", p.Name)
+		}
+	}
+
+	// optional durations, empty values fall back to the defaults
+	for _, d := range []struct{ name, value string }{
+		{"vault health interval", o.VaultHealthInterval},
+		{"grpc default timeout", o.GRPCDefaultTimeout},
+		{"grpc max timeout", o.GRPCMaxTimeout},
+		{"grpc health interval", o.GRPCHealthInterval},
+		{"batch window", o.BatchWindow},
+	} {
+		if d.value == "" {
+			continue
+		}
+
+		_, err = time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+	}
+
+	_, err = socket.ParseMode(o.SocketMode)
+	if err != nil {
+		return fmt.Errorf("invalid socket mode: %w", err)
+	}
+
+	_, err = socket.LookupUser(o.SocketOwner)
+	if err != nil {
+		return fmt.Errorf("invalid socket owner: %w", err)
+	}
+
+	_, err = socket.LookupGroup(o.SocketGroup)
+	if err != nil {
+		return fmt.Errorf("invalid socket group: %w", err)
+	}
+
+	_, err = socket.ParsePeerAllowList(o.SocketAllowedUIDs, o.SocketAllowedGIDs, o.SocketAllowedExecutables)
+	if err != nil {
+		return fmt.Errorf("invalid socket allow list: %w", err)
+	}
+
+	_, err = certs.ParseVersion(o.VaultTLSMinVersion)
+	if err != nil {
+		return err
+	}
+
+	_, err = certs.ParseCipherSuites(o.VaultTLSCipherSuites)
+	if err != nil {
+		return err
+	}
+
+	if (o.VaultClientCert == "") != (o.VaultClientKey == "") {
+		return errors.New("vault client cert and key are both required")
+	}
+
+	if o.GRPCMaxMessageSize < 0 {
+		return errors.New("grpc max message size must not be negative")
+	}
+
+	if o.GRPCMaxInFlight < 0 || o.GRPCMaxQueued < 0 || o.GRPCStatusMaxInFlight < 0 {
+		return errors.New("grpc concurrency limits must not be negative")
+	}
+
+	if o.VaultRateLimit < 0 {
+		return errors.New("vault rate limit must not be negative")
+	}
+
+	_, err = metrics.ParseBuckets(o.VaultRequestDurationBuckets)
+	if err != nil {
+		return fmt.Errorf("invalid vault request duration buckets: %w", err)
+	}
+
+	if o.BatchMaxSize < 0 {
+		return errors.New("batch max size must not be negative")
+	}
+
+	if o.TracingSampleRatio < 0 || o.TracingSampleRatio > 1 {
+		return errors.New("tracing sample ratio must be between 0 and 1")
+	}
+
+	err = o.validateEventsFlags()
+	if err != nil {
+		return err
+	}
+
+	err = o.validateLoggingFlags()
+	if err != nil {
+		return err
+	}
+
+	err = o.validateHTTPFlags()
+	if err != nil {
+		return err
+	}
+
+	err = o.validateAdminFlags()
+	if err != nil {
+		return err
+	}
+
+	return o.validateAuditFlags()
+}
+
+// vaultTLSOptions returns the TLS settings of the Vault connection.
+func (o *Options) vaultTLSOptions() vault.TLSOptions {
+	// already validated
+	minVersion, _ := certs.ParseVersion(o.VaultTLSMinVersion)
+	cipherSuites, _ := certs.ParseCipherSuites(o.VaultTLSCipherSuites)
+
+	return vault.TLSOptions{
+		ClientCertFile: o.VaultClientCert,
+		ClientKeyFile:  o.VaultClientKey,
+		CACert:         o.VaultCACert,
+		ServerName:     o.VaultTLSServerName,
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+	}
 }
 
 func (o *Options) validateJWTFlags() error {
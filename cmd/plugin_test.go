@@ -232,7 +232,7 @@ func TestNewPlugin(t *testing.T) {
 			go func() {
 				defer wg.Done()
 
-				err := NewPlugin("")
+				err := NewPlugin("", "")
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -397,6 +397,223 @@ func TestValidateFlags(t *testing.T) {
 			},
 		},
 
+		{
+			name: "invalid batch window",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				BatchWindow:          "soon",
+			},
+		},
+		{
+			name: "negative batch max size",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				BatchWindow:          "5ms",
+				BatchMaxSize:         -1,
+			},
+		},
+		{
+			name: "negative grpc max in flight",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				GRPCMaxInFlight:      -1,
+			},
+		},
+		{
+			name: "negative vault rate limit",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				VaultRateLimit:       -1,
+			},
+		},
+		{
+			name: "invalid socket allowed uid",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				SocketAllowedUIDs:    "root",
+			},
+		},
+		{
+			name: "invalid socket mode",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				SocketMode:           "rw-------",
+			},
+		},
+		{
+			name: "invalid tracing sample ratio",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				TracingSampleRatio:   1.5,
+			},
+		},
+		{
+			name: "unordered vault request duration buckets",
+			err:  true,
+			opts: &Options{
+				VaultAddress:                "e2e",
+				AuthMethod:                  "token",
+				Token:                       "token",
+				TokenRefreshInterval:        "60s",
+				VaultRequestDurationBuckets: "0.01,0.001",
+			},
+		},
+		{
+			name: "metrics tls client ca without cert",
+			err:  true,
+			opts: &Options{
+				VaultAddress:           "e2e",
+				AuthMethod:             "token",
+				Token:                  "token",
+				TokenRefreshInterval:   "60s",
+				MetricsTLSClientCAFile: "ca.crt",
+			},
+		},
+		{
+			name: "tcp health socket",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				HealthSocket:         "tcp://127.0.0.1:8080",
+			},
+		},
+		{
+			name: "invalid events object",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				EventsEnabled:        true,
+				EventsInterval:       "5m",
+				EventsObject:         "vault-kubernetes-kms-cp-1",
+			},
+		},
+		{
+			name: "zero events interval",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				EventsEnabled:        true,
+				EventsInterval:       "0s",
+			},
+		},
+		{
+			name: "invalid log encoding",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				LogEncoding:          "logfmt",
+			},
+		},
+		{
+			name: "invalid log levels",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				LogLevels:            "transit=debug",
+			},
+		},
+		{
+			name: "invalid vault tls min version",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				VaultTLSMinVersion:   "1.1",
+			},
+		},
+		{
+			name: "invalid vault tls cipher suite",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				VaultTLSCipherSuites: "TLS_RSA_WITH_RC4_128_SHA",
+			},
+		},
+		{
+			name: "vault client cert without key",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				VaultClientCert:      "/etc/kms/vault-client.crt",
+			},
+		},
+		{
+			name: "tcp socket without tls",
+			err:  true,
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				Socket:               "tcp://0.0.0.0:8443",
+				GRPCTLSCertFile:      "/etc/kms/tls.crt",
+				GRPCTLSKeyFile:       "/etc/kms/tls.key",
+			},
+		},
+		{
+			name: "tcp socket with tls",
+			opts: &Options{
+				VaultAddress:         "e2e",
+				AuthMethod:           "token",
+				Token:                "token",
+				TokenRefreshInterval: "60s",
+				Socket:               "tcp://0.0.0.0:8443",
+				GRPCTLSCertFile:      "/etc/kms/tls.crt",
+				GRPCTLSKeyFile:       "/etc/kms/tls.key",
+				GRPCTLSClientCAFile:  "/etc/kms/ca.crt",
+			},
+		},
 		{
 			name: "all plugin versions disabled",
 			err:  true,
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/certs"
+	customGRPC "github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/grpc"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/plugin"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/probes"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/socket"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"go.uber.org/zap"
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	apiVersionV1 = "v1"
+	apiVersionV2 = "v2"
+)
+
+// providersConfig is the file format of -providers-config.
+type providersConfig struct {
+	Providers []providerConfig `yaml:"providers"`
+}
+
+// providerConfig configures a KMS provider served on its own socket.
+type providerConfig struct {
+	// Name is used as provider label of all metrics and must be unique.
+	Name string `yaml:"name"`
+
+	Socket       string   `yaml:"socket"`
+	TransitMount string   `yaml:"transitMount"`
+	TransitKey   string   `yaml:"transitKey"`
+	Namespace    string   `yaml:"namespace"`
+	APIVersions  []string `yaml:"apiVersions"`
+}
+
+// providers returns the KMS providers to serve, either read from -providers-config
+// or a single provider configured by the socket, transit and disable-v1/v2 flags.
+func (o *Options) providers() ([]providerConfig, error) {
+	defaultVersions := []string{}
+
+	if !o.DisableV1 {
+		defaultVersions = append(defaultVersions, apiVersionV1)
+	}
+
+	if !o.DisableV2 {
+		defaultVersions = append(defaultVersions, apiVersionV2)
+	}
+
+	if o.ProvidersConfig == "" {
+		return []providerConfig{{
+			Name:         metrics.DefaultProvider,
+			Socket:       o.Socket,
+			TransitMount: o.TransitMount,
+			TransitKey:   o.TransitKey,
+			APIVersions:  defaultVersions,
+		}}, nil
+	}
+
+	data, err := os.ReadFile(o.ProvidersConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers config: %w", err)
+	}
+
+	cfg := &providersConfig{}
+
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse providers config: %w", err)
+	}
+
+	if len(cfg.Providers) == 0 {
+		return nil, errors.New("providers config contains no providers")
+	}
+
+	names := map[string]bool{}
+	sockets := map[string]bool{}
+
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+
+		if p.TransitMount == "" {
+			p.TransitMount = o.TransitMount
+		}
+
+		if len(p.APIVersions) == 0 {
+			p.APIVersions = defaultVersions
+		}
+
+		err = p.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider %d: %w", i, err)
+		}
+
+		if names[p.Name] || sockets[p.Socket] {
+			return nil, fmt.Errorf("invalid provider %d: name and socket must be unique", i)
+		}
+
+		names[p.Name], sockets[p.Socket] = true, true
+	}
+
+	return cfg.Providers, nil
+}
+
+func (p *providerConfig) validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("name required")
+	case p.TransitKey == "":
+		return errors.New("transit key required")
+	case len(p.APIVersions) == 0:
+		return errors.New("at least one kms plugin version must be enabled")
+	}
+
+	for _, v := range p.APIVersions {
+		if v != apiVersionV1 && v != apiVersionV2 {
+			return fmt.Errorf("invalid api version %q. Supported: v1, v2", v)
+		}
+	}
+
+	_, err := socket.NewSocket(p.Socket)
+	if err != nil {
+		return fmt.Errorf("invalid socket: %w", err)
+	}
+
+	return nil
+}
+
+// grpcTLSConfig returns the mutual TLS config for tcp sockets.
+func (o *Options) grpcTLSConfig() (*tls.Config, error) {
+	keyPair, err := certs.NewKeyPair(o.GRPCTLSCertFile, o.GRPCTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grpc tls key pair: %w", err)
+	}
+
+	clientCAs, err := certs.NewCertPool(o.GRPCTLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grpc tls client ca: %w", err)
+	}
+
+	var allowedSANs []string
+
+	for _, san := range strings.Split(o.GRPCTLSAllowedClientSANs, ",") {
+		if san = strings.TrimSpace(san); san != "" {
+			allowedSANs = append(allowedSANs, san)
+		}
+	}
+
+	return certs.ServerConfig(keyPair, clientCAs, allowedSANs), nil
+}
+
+// provider is a KMS provider served on its own socket.
+type provider struct {
+	server   *grpc.Server
+	listener net.Listener
+	probers  []probes.Prober
+}
+
+// newProvider listens on the socket of the provider and registers the enabled KMS plugins,
+// using a client for the providers transit key, that shares the authentication of vc.
+//
+//nolint:funlen
+func (o *Options) newProvider(ctx context.Context, p providerConfig, vc *vault.Client, vaultChecks []probes.Prober, auditLogger *audit.Logger) (*provider, error) {
+	s, err := socket.NewSocket(p.Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	// already validated
+	peerAllowList, _ := socket.ParsePeerAllowList(o.SocketAllowedUIDs, o.SocketAllowedGIDs, o.SocketAllowedExecutables)
+	socketMode, _ := socket.ParseMode(o.SocketMode)
+	socketOwner, _ := socket.LookupUser(o.SocketOwner)
+	socketGroup, _ := socket.LookupGroup(o.SocketGroup)
+
+	listenOpts := []socket.ListenOption{
+		socket.WithProvider(p.Name),
+		socket.WithPeerAllowList(peerAllowList),
+		socket.WithMode(socketMode),
+		socket.WithOwner(socketOwner, socketGroup),
+	}
+
+	// audit entries identify the calling process
+	if auditLogger != nil {
+		listenOpts = append(listenOpts, socket.WithPeerCredentials())
+	}
+
+	listener, err := s.Listen(o.ForceSocketOverwrite, listenOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket %s: %w", s.Path, err)
+	}
+
+	zap.L().Info("Listening for connection", zap.String("provider", p.Name), zap.String("socket", s.Path))
+
+	grpcDefaultTimeout, _ := time.ParseDuration(o.GRPCDefaultTimeout)
+	grpcMaxTimeout, _ := time.ParseDuration(o.GRPCMaxTimeout)
+
+	serverOpts := customGRPC.ServerOptions(customGRPC.Config{
+		DefaultTimeout:    grpcDefaultTimeout,
+		MaxTimeout:        grpcMaxTimeout,
+		MaxMessageSize:    o.GRPCMaxMessageSize,
+		MaxInFlight:       o.GRPCMaxInFlight,
+		MaxQueued:         o.GRPCMaxQueued,
+		StatusMaxInFlight: o.GRPCStatusMaxInFlight,
+		Provider:          p.Name,
+		Audit:             auditLogger,
+		Zeroize:           o.HardenedMemory,
+	})
+
+	// tcp sockets are reachable from other hosts and always require mutual tls
+	if s.IsTCP() {
+		tlsConfig, err := o.grpcTLSConfig()
+		if err != nil {
+			listener.Close()
+
+			return nil, err
+		}
+
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(serverOpts...)
+
+	transit := vc.ForTransitKey(p.TransitMount, p.TransitKey, p.Namespace)
+	probers := []probes.Prober{}
+
+	// the kms services share the vault checks, but report their status separately
+	grpcHealth := customGRPC.NewHealthChecker()
+
+	if slices.Contains(p.APIVersions, apiVersionV1) {
+		pluginV1 := plugin.NewPluginV1(transit)
+		pluginV1.Register(grpcServer)
+
+		probers = append(probers, pluginV1)
+		grpcHealth.AddService(pluginV1.ServiceName(), append(slices.Clone(vaultChecks), pluginV1)...)
+
+		zap.L().Info("Successfully registered kms plugin v1", zap.String("provider", p.Name))
+	}
+
+	if slices.Contains(p.APIVersions, apiVersionV2) {
+		pluginV2 := plugin.NewPluginV2(transit)
+		pluginV2.Register(grpcServer)
+
+		probers = append(probers, pluginV2)
+		grpcHealth.AddService(pluginV2.ServiceName(), append(slices.Clone(vaultChecks), pluginV2)...)
+
+		zap.L().Info("Successfully registered kms plugin v2", zap.String("provider", p.Name))
+	}
+
+	grpcHealth.Register(grpcServer)
+
+	grpcHealthInterval, _ := time.ParseDuration(o.GRPCHealthInterval)
+
+	go grpcHealth.Run(ctx, grpcHealthInterval)
+
+	if o.GRPCReflection {
+		reflection.Register(grpcServer)
+	}
+
+	return &provider{
+		server:   grpcServer,
+		listener: listener,
+		probers:  probers,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeProvidersConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+//nolint:funlen
+func TestProviders(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+		opts   Options
+		exp    []providerConfig
+		err    bool
+	}{
+		{
+			name: "single provider from flags",
+			opts: Options{
+				Socket:       "unix:///opt/kms/vaultkms.socket",
+				TransitMount: "transit",
+				TransitKey:   "kms",
+				DisableV1:    true,
+			},
+			exp: []providerConfig{{
+				Name:         "default",
+				Socket:       "unix:///opt/kms/vaultkms.socket",
+				TransitMount: "transit",
+				TransitKey:   "kms",
+				APIVersions:  []string{"v2"},
+			}},
+		},
+		{
+			name: "multiple providers",
+			opts: Options{TransitMount: "transit"},
+			config: `
+providers:
+  - name: secrets
+    socket: unix:///opt/kms/secrets.socket
+    transitKey: secrets
+    namespace: team-a
+    apiVersions: [v2]
+  - name: configmaps
+    socket: unix:///opt/kms/configmaps.socket
+    transitMount: transit-cm
+    transitKey: configmaps
+`,
+			exp: []providerConfig{
+				{
+					Name:         "secrets",
+					Socket:       "unix:///opt/kms/secrets.socket",
+					TransitMount: "transit",
+					TransitKey:   "secrets",
+					Namespace:    "team-a",
+					APIVersions:  []string{"v2"},
+				},
+				{
+					Name:         "configmaps",
+					Socket:       "unix:///opt/kms/configmaps.socket",
+					TransitMount: "transit-cm",
+					TransitKey:   "configmaps",
+					APIVersions:  []string{"v1", "v2"},
+				},
+			},
+		},
+		{
+			name:   "no providers",
+			config: `providers: []`,
+			err:    true,
+		},
+		{
+			name: "duplicate socket",
+			config: `
+providers:
+  - name: a
+    socket: unix:///opt/kms/kms.socket
+    transitKey: a
+  - name: b
+    socket: unix:///opt/kms/kms.socket
+    transitKey: b
+`,
+			err: true,
+		},
+		{
+			name: "missing transit key",
+			config: `
+providers:
+  - name: a
+    socket: unix:///opt/kms/a.socket
+`,
+			err: true,
+		},
+		{
+			name: "invalid api version",
+			config: `
+providers:
+  - name: a
+    socket: unix:///opt/kms/a.socket
+    transitKey: a
+    apiVersions: [v3]
+`,
+			err: true,
+		},
+		{
+			name: "invalid socket",
+			config: `
+providers:
+  - name: a
+    socket: /opt/kms/a.socket
+    transitKey: a
+`,
+			err: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.config != "" {
+				tc.opts.ProvidersConfig = writeProvidersConfig(t, tc.config)
+			}
+
+			providers, err := tc.opts.providers()
+			if tc.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, providers)
+		})
+	}
+}
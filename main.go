@@ -5,14 +5,26 @@ import (
 	"os"
 
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/cmd"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 )
 
-var version = "0.0.1-dev"
+var (
+	version = "0.0.1-dev"
+	commit  = "none"
+)
 
 func main() {
-	err := cmd.NewPlugin(version)
+	var err error
+
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		err = cmd.VerifyAudit(os.Args[2:])
+	} else {
+		err = cmd.NewPlugin(version, commit)
+	}
+
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		// errors of the Vault SDK may contain secrets
+		fmt.Fprintln(os.Stderr, logging.Redact(err.Error()))
 
 		os.Exit(1)
 	}
@@ -0,0 +1,85 @@
+// Package admin serves runtime debug endpoints: pprof profiles, the log level, the effective configuration
+// and the metadata of the current Vault token.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"go.uber.org/zap"
+)
+
+// Config configures the admin endpoints.
+type Config struct {
+	// LogLevel is the level of the logger, that is changed by PUT /loglevel.
+	LogLevel zap.AtomicLevel
+
+	// Config is the effective configuration served by /config, secrets must already be redacted.
+	Config any
+
+	// TokenInfo returns the metadata of the current Vault token served by /token.
+	TokenInfo func(ctx context.Context) (*vault.TokenInfo, error)
+}
+
+// NewHandler returns the handler of the admin endpoints:
+//
+//   - /debug/pprof/: pprof profiles, except for the command line, which contains flags like -token
+//   - /loglevel: GET returns the log level, PUT changes it, e.g. {"level":"debug"}
+//   - /config: the effective configuration
+//   - /token: the accessor, policies and TTL of the current Vault token
+func NewHandler(cfg Config) http.Handler {
+	mux := &http.ServeMux{}
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.Handle("/loglevel", logLevelHandler(cfg.LogLevel))
+
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, cfg.Config)
+	})
+
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		info, err := cfg.TokenInfo(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": logging.Redact(err.Error())})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, info)
+	})
+
+	return mux
+}
+
+// logLevelHandler logs changes of the log level.
+func logLevelHandler(level zap.AtomicLevel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		previous := level.Level()
+
+		level.ServeHTTP(w, r)
+
+		if current := level.Level(); current != previous {
+			logging.L(logging.ComponentAdmin).Warn("changed log level", zap.Stringer("from", previous), zap.Stringer("to", current), zap.String("client", r.RemoteAddr))
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		logging.L(logging.ComponentAdmin).Error("failed to write admin response", zap.Error(err))
+	}
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func serve(t *testing.T, h http.Handler, method, path, body string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	return rec.Code, rec.Body.String()
+}
+
+func TestNewHandler(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	var tokenErr error
+
+	h := NewHandler(Config{
+		LogLevel: level,
+		Config:   map[string]any{"VAULT_KMS_TOKEN": "<redacted>"},
+		TokenInfo: func(context.Context) (*vault.TokenInfo, error) {
+			return &vault.TokenInfo{Accessor: "accessor", Policies: []string{"kms"}, TTL: "1h0m0s"}, tokenErr
+		},
+	})
+
+	code, body := serve(t, h, http.MethodGet, "/loglevel", "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"level":"info"}`, body)
+
+	code, _ = serve(t, h, http.MethodPut, "/loglevel", `{"level":"debug"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+
+	code, _ = serve(t, h, http.MethodPut, "/loglevel", `{"level":"verbose"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+
+	code, body = serve(t, h, http.MethodGet, "/config", "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"VAULT_KMS_TOKEN":"<redacted>"}`, body)
+
+	code, body = serve(t, h, http.MethodGet, "/token", "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"accessor":"accessor","display_name":"","policies":["kms"],"ttl":"1h0m0s","renewable":false}`, body)
+
+	tokenErr = errors.New("permission denied, token=s3cr3t")
+
+	code, body = serve(t, h, http.MethodGet, "/token", "")
+	require.Equal(t, http.StatusBadGateway, code)
+	require.Contains(t, body, "permission denied")
+	require.NotContains(t, body, "s3cr3t")
+
+	code, body = serve(t, h, http.MethodGet, "/debug/pprof/", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, "goroutine")
+
+	// the command line may contain secrets passed as flags
+	code, _ = serve(t, h, http.MethodGet, "/debug/pprof/cmdline", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = serve(t, h, http.MethodPost, "/config", "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
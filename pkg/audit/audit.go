@@ -0,0 +1,224 @@
+// Package audit writes a tamper-evident log of KMS operations.
+// Every entry contains the HMAC of the previous entry and is authenticated by its own HMAC,
+// so that modified, reordered or deleted entries are detected by Verify.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// hmacField separates the authenticated payload of a line from its HMAC.
+const hmacField = `,"hmac":"`
+
+// Outcome of an operation.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry is a single audited KMS operation. It never contains plaintext.
+type Entry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+
+	Provider   string `json:"provider"`
+	APIVersion string `json:"api_version"`
+	Method     string `json:"method"`
+	UID        string `json:"uid,omitempty"`
+	KeyID      string `json:"key_id,omitempty"`
+
+	Peer            Peer     `json:"peer"`
+	VaultRequestIDs []string `json:"vault_request_ids,omitempty"`
+
+	Outcome   string  `json:"outcome"`
+	Code      string  `json:"code"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+
+	// Prev is the HMAC of the previous entry, empty for the first entry of a chain.
+	Prev string `json:"prev"`
+}
+
+// Peer identifies the caller of an operation.
+type Peer struct {
+	Address    string  `json:"address,omitempty"`
+	PID        int32   `json:"pid,omitempty"`
+	UID        *uint32 `json:"uid,omitempty"`
+	GID        *uint32 `json:"gid,omitempty"`
+	Executable string  `json:"executable,omitempty"`
+	TLSSubject string  `json:"tls_subject,omitempty"`
+}
+
+// Logger appends HMAC chained entries to a sink.
+type Logger struct {
+	sink Sink
+	key  []byte
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// NewLogger returns a logger writing to the sink. If the sink already contains entries,
+// e.g. a file written before a restart, the chain is continued.
+func NewLogger(sink Sink, key []byte) (*Logger, error) {
+	if len(key) == 0 {
+		return nil, errors.New("audit hmac key required")
+	}
+
+	l := &Logger{sink: sink, key: key}
+
+	r, ok := sink.(resumer)
+	if !ok {
+		return l, nil
+	}
+
+	last, err := r.lastLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last audit entry: %w", err)
+	}
+
+	if len(last) == 0 {
+		return l, nil
+	}
+
+	e, mac, err := parseLine(last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to continue audit log: %w", err)
+	}
+
+	l.seq, l.prev = e.Seq+1, mac
+
+	return l, nil
+}
+
+// Log writes the entry, setting its sequence number and the HMAC of the previous entry.
+// Errors are logged and counted, but do not fail the audited operation.
+func (l *Logger) Log(e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq, e.Prev = l.seq, l.prev
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		l.failed(e, err)
+
+		return
+	}
+
+	mac := sign(l.key, payload)
+
+	// {...payload...,"hmac":"<mac>"}
+	line := make([]byte, 0, len(payload)+len(hmacField)+len(mac)+2)
+	line = append(line, payload[:len(payload)-1]...)
+	line = append(line, hmacField...)
+	line = append(line, mac...)
+	line = append(line, '"', '}')
+
+	err = l.sink.Write(line)
+	if err != nil {
+		l.failed(e, err)
+
+		return
+	}
+
+	l.seq, l.prev = l.seq+1, mac
+}
+
+func (l *Logger) failed(e *Entry, err error) {
+	metrics.AuditWriteErrorsTotal.Inc()
+
+	logging.L(logging.ComponentAudit).Error("failed to write audit entry", zap.Uint64("seq", e.Seq), zap.String("method", e.Method), zap.Error(err))
+}
+
+// Close closes the sink.
+func (l *Logger) Close() error {
+	return l.sink.Close()
+}
+
+func sign(key, payload []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseLine splits a line into the entry and its HMAC.
+func parseLine(line []byte) (*Entry, string, error) {
+	i := bytes.LastIndex(line, []byte(hmacField))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", errors.New("missing hmac")
+	}
+
+	e := &Entry{}
+
+	err := json.Unmarshal(line, e)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid entry: %w", err)
+	}
+
+	return e, string(line[i+len(hmacField) : len(line)-2]), nil
+}
+
+// payload returns the authenticated part of a line.
+func payload(line []byte) []byte {
+	i := bytes.LastIndex(line, []byte(hmacField))
+
+	p := make([]byte, 0, i+1)
+	p = append(p, line[:i]...)
+
+	return append(p, '}')
+}
+
+type recorderKey struct{}
+
+// recorder collects details of an operation, that are only known to lower layers.
+type recorder struct {
+	mu              sync.Mutex
+	vaultRequestIDs []string
+}
+
+// NewContext returns a context collecting the Vault request IDs of an audited operation.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recorderKey{}, &recorder{})
+}
+
+// AddVaultRequestID records the ID of a Vault request made for the operation of the context.
+func AddVaultRequestID(ctx context.Context, id string) {
+	r, ok := ctx.Value(recorderKey{}).(*recorder)
+	if !ok || id == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.vaultRequestIDs = append(r.vaultRequestIDs, id)
+}
+
+// VaultRequestIDs returns the Vault request IDs recorded for the operation of the context.
+func VaultRequestIDs(ctx context.Context) []string {
+	r, ok := ctx.Value(recorderKey{}).(*recorder)
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.vaultRequestIDs...)
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("audit-key")
+
+func writeEntries(t *testing.T, l *Logger, n int) {
+	t.Helper()
+
+	for range n {
+		l.Log(&Entry{APIVersion: "v2", Method: "Encrypt", UID: "uid", Outcome: OutcomeSuccess, Code: "OK"})
+	}
+}
+
+func lines(buf *bytes.Buffer) []string {
+	return strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func verify(key []byte, lines []string) error {
+	_, err := Verify(key, strings.NewReader(strings.Join(lines, "")))
+
+	return err
+}
+
+func TestVerify(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	l, err := NewLogger(NewWriterSink(buf), testKey)
+	require.NoError(t, err)
+
+	writeEntries(t, l, 4)
+
+	entries := lines(buf)
+	require.Len(t, entries, 4)
+
+	res, err := Verify(testKey, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, &VerifyResult{Entries: 4, FirstSeq: 0, LastSeq: 3, Chains: 1}, res)
+
+	t.Run("wrong key", func(t *testing.T) {
+		require.ErrorContains(t, verify([]byte("other"), entries), "invalid hmac")
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := append([]string{}, entries...)
+		modified[1] = strings.Replace(modified[1], `"uid":"uid"`, `"uid":"xyz"`, 1)
+
+		require.ErrorContains(t, verify(testKey, modified), "entry 2 (seq 1): invalid hmac")
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		deleted := append([]string{}, entries[:1]...)
+		deleted = append(deleted, entries[2:]...)
+
+		require.ErrorContains(t, verify(testKey, deleted), "chain broken after seq 0")
+	})
+
+	t.Run("reordered", func(t *testing.T) {
+		reordered := []string{entries[0], entries[2], entries[1], entries[3]}
+
+		require.ErrorContains(t, verify(testKey, reordered), "chain broken")
+	})
+
+	t.Run("first entries deleted", func(t *testing.T) {
+		res, err := Verify(testKey, strings.NewReader(strings.Join(entries[2:], "")))
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), res.FirstSeq)
+	})
+
+	t.Run("hmac removed", func(t *testing.T) {
+		require.ErrorContains(t, verify(testKey, []string{`{"seq":0}`}), "missing hmac")
+	})
+}
+
+func TestVerifyRestartedChain(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	for range 2 {
+		l, err := NewLogger(NewWriterSink(buf), testKey)
+		require.NoError(t, err)
+
+		writeEntries(t, l, 2)
+	}
+
+	res, err := Verify(testKey, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Chains)
+	require.Equal(t, 4, res.Entries)
+}
+
+func TestLoggerResumesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	for range 2 {
+		sink, err := NewFileSink(path, 0, 0)
+		require.NoError(t, err)
+
+		l, err := NewLogger(sink, testKey)
+		require.NoError(t, err)
+
+		writeEntries(t, l, 2)
+		require.NoError(t, l.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	res, err := Verify(testKey, f)
+	require.NoError(t, err)
+	require.Equal(t, &VerifyResult{Entries: 4, FirstSeq: 0, LastSeq: 3, Chains: 1}, res)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(fileMode), fi.Mode().Perm())
+}
+
+func TestNewLoggerRequiresKey(t *testing.T) {
+	_, err := NewLogger(NewWriterSink(&bytes.Buffer{}), nil)
+	require.Error(t, err)
+}
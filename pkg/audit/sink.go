@@ -0,0 +1,326 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// fileMode is used for audit files, entries contain details about the callers of the plugin.
+	fileMode = 0o600
+
+	// dirMode is used when creating the parent directory of the audit file.
+	dirMode = 0o750
+
+	// syslogPriority is facility authpriv (10) and severity info (6).
+	syslogPriority = 86
+
+	// syslogAppName identifies the plugin in syslog messages.
+	syslogAppName = "vault-kubernetes-kms"
+
+	// DefaultSyslogSocket is the socket of the local syslog daemon.
+	DefaultSyslogSocket = "/dev/log"
+)
+
+// Sink receives the serialized audit entries, one per call and without a trailing newline.
+type Sink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// resumer is implemented by sinks, that can return the last entry written before a restart.
+type resumer interface {
+	lastLine() ([]byte, error)
+}
+
+// WriterSink writes entries as lines to a writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink writing entries as lines to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewStdoutSink returns a sink writing entries to stdout.
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// Write writes the line followed by a newline.
+func (s *WriterSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.w.Write(append(line, '\n'))
+
+	return err
+}
+
+// Close does nothing, the writer is owned by the caller.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends entries to a file and rotates it once it reaches its maximum size.
+// Rotated files are renamed to <path>.1 (the most recent) up to <path>.<maxBackups>.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens the audit file, a maxSize of 0 disables rotation.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	err := os.MkdirAll(filepath.Dir(path), dirMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	err = s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to stat audit file: %w", err)
+	}
+
+	s.f, s.size = f, fi.Size()
+
+	return nil
+}
+
+// Write appends the line to the file, rotating the file first if the line would exceed its maximum size.
+func (s *FileSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return errors.New("audit file closed")
+	}
+
+	n := int64(len(line)) + 1
+
+	if s.maxSize > 0 && s.size > 0 && s.size+n > s.maxSize {
+		err := s.rotate()
+		if err != nil {
+			return fmt.Errorf("failed to rotate audit file: %w", err)
+		}
+	}
+
+	_, err := s.f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	s.size += n
+
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	err := s.f.Close()
+	if err != nil {
+		return err
+	}
+
+	s.f = nil
+
+	if s.maxBackups == 0 {
+		err = os.Remove(s.path)
+	} else {
+		for i := s.maxBackups - 1; i > 0; i-- {
+			err = os.Rename(s.backup(i), s.backup(i+1))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+
+		err = os.Rename(s.path, s.backup(1))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *FileSink) backup(i int) string {
+	return s.path + "." + strconv.Itoa(i)
+}
+
+// lastLine returns the last entry of the audit file or of its most recent backup.
+func (s *FileSink) lastLine() ([]byte, error) {
+	for _, path := range []string{s.path, s.backup(1)} {
+		line, err := lastLine(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Close closes the audit file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+
+	err := s.f.Close()
+	s.f = nil
+
+	return err
+}
+
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(fi.Size()-maxLineSize, 0)
+
+	buf := make([]byte, fi.Size()-offset)
+
+	_, err = f.ReadAt(buf, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = bytes.TrimRight(buf, "\n")
+
+	return buf[bytes.LastIndexByte(buf, '\n')+1:], nil
+}
+
+// SyslogSink sends entries as RFC 5424 messages to the local syslog daemon.
+type SyslogSink struct {
+	address  string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink connects to the syslog daemon listening on the unix socket address, e.g. /dev/log.
+func NewSyslogSink(address string) (*SyslogSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	s := &SyslogSink{address: address, hostname: hostname}
+
+	err = s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	var err error
+
+	// syslog daemons usually listen on datagram sockets, some only on stream sockets
+	for _, network := range []string{"unixgram", "unix"} {
+		var conn net.Conn
+
+		conn, err = net.Dial(network, s.address)
+		if err == nil {
+			s.conn = conn
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to connect to syslog socket %s: %w", s.address, err)
+}
+
+// Write sends the entry, reconnecting once if the syslog daemon was restarted.
+func (s *SyslogSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	msg := fmt.Appendf(nil, "<%d>1 %s %s %s %d - - %s\n",
+		syslogPriority, time.Now().UTC().Format(time.RFC3339Nano), s.hostname, syslogAppName, os.Getpid(), line)
+
+	if s.conn != nil {
+		_, err := s.conn.Write(msg)
+		if err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(msg)
+
+	return err
+}
+
+// Close closes the connection to the syslog daemon.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
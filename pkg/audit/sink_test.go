@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path, 1024, 2)
+	require.NoError(t, err)
+
+	l, err := NewLogger(sink, testKey)
+	require.NoError(t, err)
+
+	writeEntries(t, l, 30)
+	require.NoError(t, l.Close())
+
+	require.NoFileExists(t, path+".3")
+
+	var readers []io.Reader
+
+	for _, p := range []string{path + ".2", path + ".1", path} {
+		fi, err := os.Stat(p)
+		require.NoError(t, err)
+		require.LessOrEqual(t, fi.Size(), int64(1024))
+
+		f, err := os.Open(p)
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		readers = append(readers, f)
+	}
+
+	// the oldest entries were removed with the oldest backup, the remaining files form one chain
+	res, err := Verify(testKey, readers...)
+	require.NoError(t, err)
+	require.Equal(t, uint64(29), res.LastSeq)
+	require.NotZero(t, res.FirstSeq)
+	require.Equal(t, 1, res.Chains)
+
+	// the chain continues from the backup, if the current file is empty
+	require.NoError(t, os.Truncate(path, 0))
+
+	sink, err = NewFileSink(path, 1024, 2)
+	require.NoError(t, err)
+
+	l, err = NewLogger(sink, testKey)
+	require.NoError(t, err)
+
+	last, err := lastLine(path + ".1")
+	require.NoError(t, err)
+
+	e, mac, err := parseLine(last)
+	require.NoError(t, err)
+	require.Equal(t, e.Seq+1, l.seq)
+	require.Equal(t, mac, l.prev)
+}
+
+func TestSyslogSink(t *testing.T) {
+	// unix socket paths are limited in length
+	dir, err := os.MkdirTemp("", "syslog")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	addr := filepath.Join(dir, "log")
+
+	conn, err := net.ListenPacket("unixgram", addr)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	sink, err := NewSyslogSink(addr)
+	require.NoError(t, err)
+
+	defer sink.Close()
+
+	l, err := NewLogger(sink, testKey)
+	require.NoError(t, err)
+
+	writeEntries(t, l, 1)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	buf := make([]byte, 4096)
+
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	require.True(t, strings.HasPrefix(msg, "<86>1 "), msg)
+	require.Contains(t, msg, " vault-kubernetes-kms ")
+
+	// the message is the audit entry
+	entry := msg[strings.Index(msg, " - - ")+len(" - - "):]
+
+	_, err = Verify(testKey, strings.NewReader(entry))
+	require.NoError(t, err)
+}
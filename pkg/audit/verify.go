@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"fmt"
+	"io"
+)
+
+// maxLineSize is the maximum size of an audit entry accepted by Verify.
+const maxLineSize = 1 << 20
+
+// VerifyResult summarizes a verified audit log.
+type VerifyResult struct {
+	Entries  int
+	FirstSeq uint64
+	LastSeq  uint64
+
+	// Chains is the number of chains, a new chain starts when the plugin restarted without continuing the log.
+	Chains int
+}
+
+// Verify checks the HMAC of every entry and that every entry references its predecessor.
+// Multiple readers are verified as one log, e.g. rotated files from the oldest to the current one.
+// The first entry may reference an entry of an older file, that is not verified.
+func Verify(key []byte, readers ...io.Reader) (*VerifyResult, error) {
+	res := &VerifyResult{}
+
+	var prev string
+
+	for _, r := range readers {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize) //nolint: mnd
+
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			e, mac, err := parseLine(line)
+			if err != nil {
+				return res, fmt.Errorf("entry %d: %w", res.Entries+1, err)
+			}
+
+			if !hmac.Equal([]byte(mac), []byte(sign(key, payload(line)))) {
+				return res, fmt.Errorf("entry %d (seq %d): invalid hmac, the entry was modified or signed with another key", res.Entries+1, e.Seq)
+			}
+
+			switch {
+			case res.Entries == 0:
+				res.FirstSeq, res.Chains = e.Seq, 1
+			case e.Seq == 0 && e.Prev == "":
+				res.Chains++
+			case e.Seq != res.LastSeq+1 || e.Prev != prev:
+				return res, fmt.Errorf("entry %d (seq %d): chain broken after seq %d, entries were deleted or reordered", res.Entries+1, e.Seq, res.LastSeq)
+			}
+
+			res.Entries++
+			res.LastSeq, prev = e.Seq, mac
+		}
+
+		if err := scanner.Err(); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
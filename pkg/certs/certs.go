@@ -0,0 +1,262 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"go.uber.org/zap"
+)
+
+// checkInterval is the minimum interval between two checks whether the files changed.
+const checkInterval = 5 * time.Second
+
+// reloader reloads a value from files, once their modification time or size changed.
+// Changes are detected lazily, when the value is requested.
+type reloader[T any] struct {
+	paths []string
+	load  func() (T, error)
+
+	mu        sync.Mutex
+	value     T
+	state     string
+	lastCheck time.Time
+
+	now func() time.Time
+}
+
+func newReloader[T any](load func() (T, error), paths ...string) (*reloader[T], error) {
+	r := &reloader[T]{
+		paths: paths,
+		load:  load,
+		now:   time.Now,
+	}
+
+	state, err := filesState(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	r.value, err = load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.state, r.lastCheck = state, r.now()
+
+	return r, nil
+}
+
+// get returns the current value and reloads it, if the files changed.
+// If reloading fails, the previous value is kept.
+func (r *reloader[T]) get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Sub(r.lastCheck) < checkInterval {
+		return r.value
+	}
+
+	r.lastCheck = r.now()
+
+	state, err := filesState(r.paths)
+	if err != nil || state == r.state {
+		return r.value
+	}
+
+	value, err := r.load()
+	if err != nil {
+		logging.L(logging.ComponentCerts).Warn("failed to reload certificates, keeping the previous ones", zap.Strings("paths", r.paths), zap.Error(err))
+
+		return r.value
+	}
+
+	logging.L(logging.ComponentCerts).Info("reloaded certificates", zap.Strings("paths", r.paths))
+
+	r.value, r.state = value, state
+
+	return r.value
+}
+
+// filesState returns a fingerprint of the modification times and sizes of the files and the files within directories.
+func filesState(paths []string) (string, error) {
+	var state string
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		state += fmt.Sprintf("%s:%d:%d;", path, fi.ModTime().UnixNano(), fi.Size())
+
+		if !fi.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return "", err
+		}
+
+		for _, e := range entries {
+			info, err := os.Stat(filepath.Join(path, e.Name()))
+			if err != nil {
+				continue
+			}
+
+			state += fmt.Sprintf("%s:%d:%d;", e.Name(), info.ModTime().UnixNano(), info.Size())
+		}
+	}
+
+	return state, nil
+}
+
+// KeyPair is a certificate and private key read from PEM files, that is reloaded once the files change.
+type KeyPair struct {
+	r *reloader[*tls.Certificate]
+}
+
+// NewKeyPair loads a key pair from the certificate and key file.
+// If keyFile is empty, certFile must contain both the certificate and the private key (e.g. kubelet-client-current.pem).
+// The key material is only kept in memory.
+func NewKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	paths := []string{certFile}
+	if keyFile != "" {
+		paths = append(paths, keyFile)
+	}
+
+	r, err := newReloader(func() (*tls.Certificate, error) {
+		return loadKeyPair(certFile, keyFile)
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{r: r}, nil
+}
+
+// Certificate returns the current certificate.
+func (k *KeyPair) Certificate() *tls.Certificate {
+	return k.r.get()
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (k *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate.
+func (k *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
+
+func loadKeyPair(certFile, keyFile string) (*tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read certificate file %s: %w", certFile, err)
+	}
+
+	keyPEM := certPEM
+
+	if keyFile != "" {
+		keyPEM, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key file %s: %w", keyFile, err)
+		}
+	}
+
+	cert, err := parseKeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key pair %s: %w", certFile, err)
+	}
+
+	return cert, nil
+}
+
+// parseKeyPair parses the key pair and wipes the PEM encoded private key, the certificate keeps decoded copies.
+func parseKeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	defer secure.Wipe(keyPEM)
+
+	// X509KeyPair skips PEM blocks of other types, so a combined PEM file can be used for both
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+// CertPool is a pool of CA certificates read from PEM files or directories, that is reloaded once the files change.
+type CertPool struct {
+	r *reloader[*x509.CertPool]
+}
+
+// NewCertPool loads all certificates of the specified files and directories.
+func NewCertPool(paths ...string) (*CertPool, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no CA certificates specified")
+	}
+
+	r, err := newReloader(func() (*x509.CertPool, error) {
+		return loadCertPool(paths)
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertPool{r: r}, nil
+}
+
+// Pool returns the current certificate pool.
+func (p *CertPool) Pool() *x509.CertPool {
+	return p.r.get()
+}
+
+func loadCertPool(paths []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+
+		if fi.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+
+			files = files[:0]
+
+			for _, e := range entries {
+				if !e.IsDir() {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read CA file %s: %w", file, err)
+			}
+
+			if !pool.AppendCertsFromPEM(data) && !fi.IsDir() {
+				return nil, fmt.Errorf("no certificates found in %s", file)
+			}
+		}
+	}
+
+	return pool, nil
+}
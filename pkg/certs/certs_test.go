@@ -0,0 +1,281 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and private key signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, dnsNames ...string) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestKeyPairReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	cert, key := ca.issue(t, "first")
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	kp, err := NewKeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	require.Equal(t, "first", kp.Certificate().Leaf.Subject.CommonName)
+
+	now := time.Now()
+	kp.r.now = func() time.Time { return now }
+
+	cert, key = ca.issue(t, "second")
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+	require.NoError(t, os.Chtimes(certFile, now.Add(time.Minute), now.Add(time.Minute)))
+
+	// changes are only detected after the check interval
+	require.Equal(t, "first", kp.Certificate().Leaf.Subject.CommonName)
+
+	now = now.Add(checkInterval)
+	require.Equal(t, "second", kp.Certificate().Leaf.Subject.CommonName)
+
+	// an invalid key pair keeps the previous one
+	writeFile(t, keyFile, []byte("invalid"))
+	require.NoError(t, os.Chtimes(keyFile, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+
+	now = now.Add(checkInterval)
+	require.Equal(t, "second", kp.Certificate().Leaf.Subject.CommonName)
+}
+
+func TestKeyPairCombinedPEM(t *testing.T) {
+	ca := newTestCA(t)
+
+	cert, key := ca.issue(t, "kubelet")
+
+	path := filepath.Join(t.TempDir(), "kubelet-client-current.pem")
+	writeFile(t, path, append(cert, key...))
+
+	kp, err := NewKeyPair(path, "")
+	require.NoError(t, err)
+	require.Equal(t, "kubelet", kp.Certificate().Leaf.Subject.CommonName)
+
+	writeFile(t, path, cert)
+
+	_, err = NewKeyPair(path, "")
+	require.Error(t, err)
+}
+
+func TestParseKeyPairWipesKey(t *testing.T) {
+	ca := newTestCA(t)
+
+	cert, key := ca.issue(t, "kms")
+
+	kp, err := parseKeyPair(cert, key)
+	require.NoError(t, err)
+	require.NotNil(t, kp.PrivateKey)
+	require.True(t, secure.IsZero(key))
+
+	// the key is wiped on errors as well
+	_, key = ca.issue(t, "kms")
+
+	_, err = parseKeyPair([]byte("invalid"), key)
+	require.Error(t, err)
+	require.True(t, secure.IsZero(key))
+}
+
+func TestCertPool(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "a.pem"), newTestCA(t).pem)
+	writeFile(t, filepath.Join(dir, "b.pem"), newTestCA(t).pem)
+
+	pool, err := NewCertPool(dir)
+	require.NoError(t, err)
+	require.Len(t, pool.Pool().Subjects(), 2) //nolint:staticcheck
+
+	invalid := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, invalid, []byte("invalid"))
+
+	_, err = NewCertPool(invalid)
+	require.Error(t, err)
+
+	_, err = NewCertPool()
+	require.Error(t, err)
+}
+
+//nolint:funlen
+func TestServerConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	serverCert, serverKey := ca.issue(t, "server", "kms.example")
+	writeFile(t, filepath.Join(dir, "server.crt"), serverCert)
+	writeFile(t, filepath.Join(dir, "server.key"), serverKey)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+
+	kp, err := NewKeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	require.NoError(t, err)
+
+	clientCAs, err := NewCertPool(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+
+	clientCert := func(ca *testCA, dnsName string) []tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "client", dnsName)
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+
+		return []tls.Certificate{cert}
+	}
+
+	testCases := []struct {
+		name        string
+		allowedSANs []string
+		clientCerts []tls.Certificate
+		err         bool
+	}{
+		{
+			name:        "client certificate signed by the ca",
+			clientCerts: clientCert(ca, "kube-apiserver"),
+		},
+		{
+			name:        "allowed san",
+			allowedSANs: []string{"kube-apiserver"},
+			clientCerts: clientCert(ca, "kube-apiserver"),
+		},
+		{
+			name:        "san not allowed",
+			allowedSANs: []string{"kube-apiserver"},
+			clientCerts: clientCert(ca, "other"),
+			err:         true,
+		},
+		{
+			name: "no client certificate",
+			err:  true,
+		},
+		{
+			name:        "client certificate signed by another ca",
+			clientCerts: clientCert(newTestCA(t), "kube-apiserver"),
+			err:         true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			serverConn, clientConn := net.Pipe()
+
+			defer serverConn.Close()
+			defer clientConn.Close()
+
+			serverErr := make(chan error, 1)
+
+			go func() {
+				server := tls.Server(serverConn, ServerConfig(kp, clientCAs, tc.allowedSANs))
+				serverErr <- server.Handshake()
+
+				_ = server.Close()
+			}()
+
+			client := tls.Client(clientConn, &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				ServerName:   "kms.example",
+				RootCAs:      rootCAs,
+				Certificates: tc.clientCerts,
+			})
+
+			if client.Handshake() == nil {
+				// with TLS 1.3 the server rejects the client certificate after the client handshake completed,
+				// read to receive the alert or the close of the server
+				_, _ = client.Read(make([]byte, 1))
+			}
+
+			err := <-serverErr
+			if tc.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ClientConfig returns a copy of base, or a new config if base is nil. If keyPair is set, its certificate is presented
+// to the server. If rootCAs is set, the server certificate is verified against the current pool instead of the
+// RootCAs of base, so that rotated CA files are used without a restart.
+// Servers are verified against the name sent via SNI, which is the ServerName of the config or the host dialed.
+// IP addresses are not sent via SNI, so servers without SNI name are verified against host instead,
+// e.g. the IP address of the server.
+func ClientConfig(base *tls.Config, keyPair *KeyPair, rootCAs *CertPool, host string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+
+	if keyPair != nil {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = keyPair.GetClientCertificate
+	}
+
+	if rootCAs != nil {
+		// the default verification uses a static pool, verify the chain against the current pool instead
+		cfg.InsecureSkipVerify = true //nolint: gosec
+		cfg.VerifyConnection = verifyServer(rootCAs, host)
+	}
+
+	return cfg
+}
+
+func verifyServer(rootCAs *CertPool, host string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server certificate required")
+		}
+
+		name := cs.ServerName
+		if name == "" {
+			name = host
+		}
+
+		if name == "" {
+			return errors.New("server name required to verify the server certificate")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       name,
+			Roots:         rootCAs.Pool(),
+			Intermediates: intermediates,
+		})
+
+		return err
+	}
+}
+
+// ParseVersion parses a TLS version such as "1.2" or "1.3", an empty string returns TLS 1.2.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid tls version %q. Supported: 1.2, 1.3", version)
+	}
+}
+
+// ParseCipherSuites parses a comma separated list of cipher suite names, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+// Only secure cipher suites are supported. An empty string returns nil, which uses Go's defaults.
+// The cipher suites of TLS 1.3 are not configurable.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+
+	supported := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+
+	for _, name := range strings.Split(names, ",") {
+		id, ok := supported[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", strings.TrimSpace(name))
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
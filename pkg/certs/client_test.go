@@ -0,0 +1,157 @@
+package certs
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:funlen
+func TestClientConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	serverCertPEM, serverKeyPEM := ca.issue(t, "server", "vault.example")
+
+	serverCert, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	require.NoError(t, err)
+
+	clientCertPEM, clientKeyPEM := ca.issue(t, "client")
+	writeFile(t, filepath.Join(dir, "client.crt"), clientCertPEM)
+	writeFile(t, filepath.Join(dir, "client.key"), clientKeyPEM)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	writeFile(t, filepath.Join(dir, "other-ca.crt"), newTestCA(t).pem)
+
+	keyPair, err := NewKeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	require.NoError(t, err)
+
+	rootCAs, err := NewCertPool(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+
+	otherCAs, err := NewCertPool(filepath.Join(dir, "other-ca.crt"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		rootCAs    *CertPool
+		serverName string
+		host       string
+		err        bool
+	}{
+		{
+			name:       "trusted server",
+			rootCAs:    rootCAs,
+			serverName: "vault.example",
+		},
+		{
+			name:       "server name mismatch",
+			rootCAs:    rootCAs,
+			serverName: "other.example",
+			err:        true,
+		},
+		{
+			name:       "untrusted server",
+			rootCAs:    otherCAs,
+			serverName: "vault.example",
+			err:        true,
+		},
+		{
+			// IP addresses are not sent via SNI
+			name:    "ip address",
+			rootCAs: rootCAs,
+			host:    "127.0.0.1",
+		},
+		{
+			name:    "ip address mismatch",
+			rootCAs: rootCAs,
+			host:    "10.9.9.9",
+			err:     true,
+		},
+		{
+			name:    "no server name",
+			rootCAs: rootCAs,
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{serverCert},
+				ClientAuth:   tls.RequireAnyClientCert,
+			})
+			require.NoError(t, err)
+
+			defer listener.Close()
+
+			presented := make(chan int, 1)
+
+			go func() {
+				defer close(presented)
+
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				defer conn.Close()
+
+				server, _ := conn.(*tls.Conn)
+				if server.Handshake() == nil {
+					presented <- len(server.ConnectionState().PeerCertificates)
+				}
+			}()
+
+			clientConn, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+
+			defer clientConn.Close()
+
+			cfg := ClientConfig(nil, keyPair, tc.rootCAs, tc.host)
+			cfg.ServerName = tc.serverName
+
+			client := tls.Client(clientConn, cfg)
+
+			err = client.Handshake()
+			if tc.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, <-presented, "client certificate must be presented")
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("")
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), v)
+
+	v, err = ParseVersion("1.3")
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), v)
+
+	_, err = ParseVersion("1.0")
+	require.Error(t, err)
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites("")
+	require.NoError(t, err)
+	require.Nil(t, ids)
+
+	ids, err = ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, ids)
+
+	// insecure cipher suites are rejected
+	_, err = ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA")
+	require.Error(t, err)
+}
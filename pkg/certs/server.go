@@ -0,0 +1,83 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"slices"
+)
+
+// ServerConfig returns a TLS config for servers requiring mutual TLS. Client certificates must be signed by clientCAs
+// and, if allowedSANs is not empty, contain one of the allowed DNS names, IP addresses, URIs or email addresses.
+// The server certificate and the client CAs are reloaded once their files change.
+func ServerConfig(keyPair *KeyPair, clientCAs *CertPool, allowedSANs []string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:       tls.VersionTLS12,
+				GetCertificate:   keyPair.GetCertificate,
+				ClientAuth:       tls.RequireAndVerifyClientCert,
+				ClientCAs:        clientCAs.Pool(),
+				VerifyConnection: verifySANs(allowedSANs),
+			}, nil
+		},
+	}
+}
+
+func verifySANs(allowedSANs []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(allowedSANs) == 0 {
+			return nil
+		}
+
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("client certificate required")
+		}
+
+		for _, san := range subjectAltNames(cs.PeerCertificates[0]) {
+			if slices.Contains(allowedSANs, san) {
+				return nil
+			}
+		}
+
+		return errors.New("client certificate does not contain an allowed subject alternative name")
+	}
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	sans := slices.Clone(cert.DNSNames)
+	sans = append(sans, cert.EmailAddresses...)
+
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return sans
+}
+
+// HTTPServerConfig returns a TLS config for HTTP servers. If clientCAs is set, client certificates are verified
+// against it when presented, but not required, so that handlers can decide whether a client certificate is required.
+// The server certificate and the client CAs are reloaded once their files change.
+func HTTPServerConfig(keyPair *KeyPair, clientCAs *CertPool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: keyPair.GetCertificate,
+			}
+
+			if clientCAs != nil {
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				cfg.ClientCAs = clientCAs.Pool()
+			}
+
+			return cfg, nil
+		},
+	}
+}
@@ -0,0 +1,269 @@
+// Package events reports problems with the Vault authentication, the token, the transit key and the Vault server
+// as Kubernetes Events, so that they show up in kubectl get events instead of only in the logs of the plugin.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Reasons of the reported events.
+const (
+	ReasonAuthenticationFailed = "VaultAuthenticationFailed"
+	ReasonTokenExpiring        = "VaultTokenExpiring"
+	ReasonTransitKeyRotated    = "TransitKeyRotated"
+	ReasonVaultUnhealthy       = "VaultUnhealthy"
+	ReasonVaultHealthy         = "VaultHealthy"
+)
+
+const (
+	component = "vault-kubernetes-kms"
+
+	// DefaultInterval is the default minimum interval between events with the same reason.
+	DefaultInterval = 5 * time.Minute
+
+	createTimeout = 10 * time.Second
+)
+
+// Reporter creates Kubernetes Events for an object. At most one event per reason is created per interval,
+// so that a persistent problem does not flood the API server with events. State changes are not rate limited.
+// A nil Reporter discards all events.
+type Reporter struct {
+	client kubernetes.Interface
+	object corev1.ObjectReference
+	host   string
+
+	interval time.Duration
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+
+	// resolve looks up the UID of the object
+	resolve sync.Once
+
+	wg sync.WaitGroup
+}
+
+// NewReporter returns a reporter creating events for the object, see ParseObjectReference.
+// An interval of 0 uses DefaultInterval.
+func NewReporter(client kubernetes.Interface, object *corev1.ObjectReference, interval time.Duration) *Reporter {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	host, _ := os.Hostname()
+
+	return &Reporter{
+		client:   client,
+		object:   *object,
+		host:     host,
+		interval: interval,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// NewClientset returns a clientset using the kubeconfig file or the in-cluster configuration if kubeconfig is empty.
+func NewClientset(kubeconfig string) (kubernetes.Interface, error) {
+	var (
+		cfg *rest.Config
+		err error
+	)
+
+	if kubeconfig == "" {
+		cfg, err = rest.InClusterConfig()
+	} else {
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes client config: %w", err)
+	}
+
+	return kubernetes.NewForConfig(cfg)
+}
+
+// DefaultObject returns the mirror pod of the static pod of the plugin on this node,
+// which Kubernetes names after the pod and the node.
+func DefaultObject() string {
+	host, _ := os.Hostname()
+
+	return "Pod/kube-system/" + component + "-" + host
+}
+
+// ParseObjectReference parses a reference to the object, that events are reported for,
+// e.g. Pod/kube-system/vault-kubernetes-kms-cp-1 or Node/cp-1 for cluster scoped objects.
+func ParseObjectReference(s string) (*corev1.ObjectReference, error) {
+	parts := strings.Split(s, "/")
+
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid object %q, expected <kind>/<namespace>/<name> or <kind>/<name>", s)
+		}
+	}
+
+	ref := &corev1.ObjectReference{Kind: parts[0]}
+
+	switch len(parts) {
+	case 2:
+		ref.Name = parts[1]
+	case 3:
+		ref.Namespace, ref.Name = parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid object %q, expected <kind>/<namespace>/<name> or <kind>/<name>", s)
+	}
+
+	if ref.Kind == "Pod" || ref.Kind == "Node" {
+		ref.APIVersion = "v1"
+	}
+
+	return ref, nil
+}
+
+// Warning reports a problem.
+func (r *Reporter) Warning(reason, message string) {
+	r.event(corev1.EventTypeWarning, reason, message)
+}
+
+// Normal reports a change, that requires no action.
+func (r *Reporter) Normal(reason, message string) {
+	r.event(corev1.EventTypeNormal, reason, message)
+}
+
+// WarningTransition reports a change into a problematic state, e.g. the Vault server becoming unhealthy.
+// State changes are not rate limited, as they are only reported once per change and a rate limit would hide
+// a relapse shortly after a recovery.
+func (r *Reporter) WarningTransition(reason, message string) {
+	r.transition(corev1.EventTypeWarning, reason, message)
+}
+
+// NormalTransition reports a change back into a healthy state, it is not rate limited like WarningTransition.
+func (r *Reporter) NormalTransition(reason, message string) {
+	r.transition(corev1.EventTypeNormal, reason, message)
+}
+
+// Wait waits until the pending events are created, e.g. before exiting.
+func (r *Reporter) Wait() {
+	if r == nil {
+		return
+	}
+
+	r.wg.Wait()
+}
+
+func (r *Reporter) event(eventType, reason, message string) {
+	if r == nil || !r.allow(reason) {
+		return
+	}
+
+	r.emit(eventType, reason, message)
+}
+
+func (r *Reporter) transition(eventType, reason, message string) {
+	if r == nil {
+		return
+	}
+
+	r.emit(eventType, reason, message)
+}
+
+func (r *Reporter) emit(eventType, reason, message string) {
+	// events are reported from the request path, e.g. on key rotation, and must not block it
+	r.wg.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), createTimeout)
+		defer cancel()
+
+		err := r.create(ctx, eventType, reason, logging.Redact(message))
+		if err != nil {
+			logging.L(logging.ComponentEvents).Warn("failed to create kubernetes event", zap.String("reason", reason), zap.Error(err))
+		}
+	})
+}
+
+func (r *Reporter) allow(reason string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.limiters[reason]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(r.interval), 1)
+		r.limiters[reason] = limiter
+	}
+
+	return limiter.Allow()
+}
+
+func (r *Reporter) create(ctx context.Context, eventType, reason, message string) error {
+	object := r.involvedObject(ctx)
+	now := metav1.Now()
+
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	_, err := r.client.CoreV1().Events(namespace).Create(ctx, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", object.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject:      object,
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: component, Host: r.host},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: component,
+		ReportingInstance:   r.host,
+	}, metav1.CreateOptions{})
+
+	return err
+}
+
+// involvedObject returns the object with its UID, so that kubectl describe shows the events of pods and nodes.
+// The UID is looked up once, objects that cannot be read are referenced without UID.
+func (r *Reporter) involvedObject(ctx context.Context) corev1.ObjectReference {
+	r.resolve.Do(func() {
+		var err error
+
+		switch r.object.Kind {
+		case "Pod":
+			var pod *corev1.Pod
+
+			pod, err = r.client.CoreV1().Pods(r.object.Namespace).Get(ctx, r.object.Name, metav1.GetOptions{})
+			if err == nil {
+				r.object.UID = pod.UID
+			}
+		case "Node":
+			var node *corev1.Node
+
+			node, err = r.client.CoreV1().Nodes().Get(ctx, r.object.Name, metav1.GetOptions{})
+			if err == nil {
+				r.object.UID = node.UID
+			}
+		default:
+			err = errors.New("unsupported kind")
+		}
+
+		if err != nil {
+			logging.L(logging.ComponentEvents).Debug("referencing events without uid", zap.String("kind", r.object.Kind), zap.String("name", r.object.Name), zap.Error(err))
+		}
+	})
+
+	return r.object
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func listEvents(t *testing.T, client *fake.Clientset, namespace string) []corev1.Event {
+	t.Helper()
+
+	list, err := client.CoreV1().Events(namespace).List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+
+	return list.Items
+}
+
+func TestReporter(t *testing.T) {
+	client := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-kubernetes-kms-cp-1", Namespace: "kube-system", UID: "1234"},
+	})
+
+	ref, err := ParseObjectReference("Pod/kube-system/vault-kubernetes-kms-cp-1")
+	require.NoError(t, err)
+
+	r := NewReporter(client, ref, time.Hour)
+
+	r.Warning(ReasonAuthenticationFailed, "approle login to vault failed: invalid secret_id=3f1c2b7a")
+	r.Wait()
+
+	// rate limited by reason
+	r.Warning(ReasonAuthenticationFailed, "approle login to vault failed")
+	r.Normal(ReasonTransitKeyRotated, "transit key transit/kms rotated from version 1 to 2")
+	r.Wait()
+
+	events := listEvents(t, client, "kube-system")
+	require.Len(t, events, 2)
+
+	byReason := map[string]corev1.Event{}
+	for _, e := range events {
+		byReason[e.Reason] = e
+	}
+
+	auth := byReason[ReasonAuthenticationFailed]
+	require.Equal(t, corev1.EventTypeWarning, auth.Type)
+	require.Equal(t, "approle login to vault failed: invalid secret_id=<redacted>", auth.Message)
+	require.Equal(t, "Pod", auth.InvolvedObject.Kind)
+	require.Equal(t, "vault-kubernetes-kms-cp-1", auth.InvolvedObject.Name)
+	require.Equal(t, "1234", string(auth.InvolvedObject.UID))
+	require.Equal(t, component, auth.Source.Component)
+	require.EqualValues(t, 1, auth.Count)
+
+	require.Equal(t, corev1.EventTypeNormal, byReason[ReasonTransitKeyRotated].Type)
+}
+
+func TestReporterTransitionsAreNotRateLimited(t *testing.T) {
+	client := fake.NewClientset()
+	r := NewReporter(client, &corev1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "kms"}, time.Hour)
+
+	r.WarningTransition(ReasonVaultUnhealthy, "vault server is sealed and unable to serve requests")
+	r.NormalTransition(ReasonVaultHealthy, "vault server is active again")
+	r.WarningTransition(ReasonVaultUnhealthy, "vault server is sealed and unable to serve requests")
+	r.Wait()
+
+	var reasons []string
+	for _, e := range listEvents(t, client, "kube-system") {
+		reasons = append(reasons, e.Reason)
+	}
+
+	require.ElementsMatch(t, []string{ReasonVaultUnhealthy, ReasonVaultHealthy, ReasonVaultUnhealthy}, reasons)
+
+	// other events of the same reason are still rate limited
+	r.Warning(ReasonVaultUnhealthy, "failed to check vault server state")
+	r.Warning(ReasonVaultUnhealthy, "failed to check vault server state")
+	r.Wait()
+
+	require.Len(t, listEvents(t, client, "kube-system"), 4)
+}
+
+func TestReporterClusterScopedObject(t *testing.T) {
+	client := fake.NewClientset()
+
+	ref, err := ParseObjectReference("Node/cp-1")
+	require.NoError(t, err)
+
+	r := NewReporter(client, ref, 0)
+	r.Warning(ReasonVaultUnhealthy, "vault server is sealed and unable to serve requests")
+	r.Wait()
+
+	events := listEvents(t, client, metav1.NamespaceDefault)
+	require.Len(t, events, 1)
+	require.Empty(t, events[0].InvolvedObject.UID, "unknown objects are referenced without uid")
+}
+
+func TestNilReporter(t *testing.T) {
+	var r *Reporter
+
+	r.Warning(ReasonVaultUnhealthy, "discarded")
+	r.Normal(ReasonVaultHealthy, "discarded")
+	r.WarningTransition(ReasonVaultUnhealthy, "discarded")
+	r.Wait()
+}
+
+func TestParseObjectReference(t *testing.T) {
+	ref, err := ParseObjectReference("Pod/kube-system/vault-kubernetes-kms-cp-1")
+	require.NoError(t, err)
+	require.Equal(t, &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "kube-system", Name: "vault-kubernetes-kms-cp-1"}, ref)
+
+	ref, err = ParseObjectReference("Node/cp-1")
+	require.NoError(t, err)
+	require.Equal(t, &corev1.ObjectReference{APIVersion: "v1", Kind: "Node", Name: "cp-1"}, ref)
+
+	for _, s := range []string{"", "Pod", "Pod//name", "Pod/kube-system/name/extra"} {
+		_, err := ParseObjectReference(s)
+		require.Error(t, err, s)
+	}
+}
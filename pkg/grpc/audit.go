@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/socket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// auditedMethods are the KMS methods written to the audit log.
+var auditedMethods = map[string]bool{
+	"Encrypt": true,
+	"Decrypt": true,
+	"Status":  true,
+}
+
+// AuditInterceptor writes an audit entry for every Encrypt, Decrypt and Status request of the v1beta1 and v2 KMS API.
+// The entry contains the request metadata, the caller and the outcome, but never the plaintext.
+func AuditInterceptor(logger *audit.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		apiVersion, method := kmsMethod(info.FullMethod)
+		if !auditedMethods[method] {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		ctx = audit.NewContext(ctx)
+
+		resp, err := handler(ctx, req)
+
+		e := &audit.Entry{
+			Time:            start.UTC(),
+			Provider:        metrics.Provider(ctx),
+			APIVersion:      apiVersion,
+			Method:          method,
+			UID:             stringField(req, "uid"),
+			KeyID:           stringField(req, "key_id"),
+			Peer:            auditPeer(ctx),
+			VaultRequestIDs: audit.VaultRequestIDs(ctx),
+			Outcome:         audit.OutcomeSuccess,
+			Code:            status.Code(err).String(),
+			LatencyMS:       float64(time.Since(start).Microseconds()) / 1000, //nolint: mnd
+		}
+
+		if err != nil {
+			e.Outcome, e.Error = audit.OutcomeFailure, logging.Redact(status.Convert(err).Message())
+		} else if keyID := stringField(resp, "key_id"); keyID != "" {
+			// the key used for encryption and the current key are only known from the response
+			e.KeyID = keyID
+		}
+
+		logger.Log(e)
+
+		return resp, err
+	}
+}
+
+// kmsMethod splits a full method name like /v2.KeyManagementService/Encrypt into the API version and the method.
+// Methods of other services return an empty method.
+func kmsMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", ""
+	}
+
+	apiVersion, service, ok := strings.Cut(service, ".")
+	if !ok || service != "KeyManagementService" {
+		return "", ""
+	}
+
+	return apiVersion, method
+}
+
+// stringField returns the value of a string field of a proto message, empty if the field does not exist.
+func stringField(v any, name protoreflect.Name) string {
+	msg, ok := v.(proto.Message)
+	if !ok || msg == nil {
+		return ""
+	}
+
+	m := msg.ProtoReflect()
+	if !m.IsValid() {
+		return ""
+	}
+
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return ""
+	}
+
+	return m.Get(fd).String()
+}
+
+func auditPeer(ctx context.Context) audit.Peer {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return audit.Peer{}
+	}
+
+	var res audit.Peer
+
+	if p.Addr != nil {
+		res.Address = p.Addr.String()
+	}
+
+	if addr, ok := p.Addr.(*socket.PeerAddr); ok && addr.Credentials != nil {
+		res.PID = addr.Credentials.PID
+		res.UID = &addr.Credentials.UID
+		res.GID = &addr.Credentials.GID
+		res.Executable = addr.Credentials.Executable
+	}
+
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		res.TLSSubject = tlsInfo.State.PeerCertificates[0].Subject.String()
+	}
+
+	return res
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "k8s.io/kms/apis/v2"
+)
+
+type auditKMS struct {
+	pb.UnimplementedKeyManagementServiceServer
+}
+
+func (a *auditKMS) Encrypt(ctx context.Context, _ *pb.EncryptRequest) (*pb.EncryptResponse, error) {
+	audit.AddVaultRequestID(ctx, "vault-request-1")
+
+	return &pb.EncryptResponse{Ciphertext: []byte("cipher"), KeyId: "3"}, nil
+}
+
+func (a *auditKMS) Decrypt(ctx context.Context, _ *pb.DecryptRequest) (*pb.DecryptResponse, error) {
+	audit.AddVaultRequestID(ctx, "vault-request-2")
+
+	return nil, status.Error(codes.Unavailable, "vault is sealed, token=s3cr3t")
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	key := []byte("key")
+	buf := &bytes.Buffer{}
+
+	logger, err := audit.NewLogger(audit.NewWriterSink(buf), key)
+	require.NoError(t, err)
+
+	s := grpc.NewServer(ServerOptions(Config{Provider: "tenant-a", Audit: logger})...)
+	pb.RegisterKeyManagementServiceServer(s, &auditKMS{})
+
+	client := pb.NewKeyManagementServiceClient(dialBufconn(t, s))
+
+	_, err = client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("secret-dek"), Uid: "uid-1"})
+	require.NoError(t, err)
+
+	_, err = client.Decrypt(t.Context(), &pb.DecryptRequest{Ciphertext: []byte("cipher"), Uid: "uid-2", KeyId: "2"})
+	require.Error(t, err)
+
+	require.NotContains(t, buf.String(), "secret-dek")
+	require.NotContains(t, buf.String(), "c2VjcmV0LWRlaw", "base64 encoded plaintext")
+	require.NotContains(t, buf.String(), "s3cr3t", "secrets in errors")
+
+	res, err := audit.Verify(key, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Entries)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var encrypt, decrypt audit.Entry
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &encrypt))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &decrypt))
+
+	require.Equal(t, "tenant-a", encrypt.Provider)
+	require.Equal(t, "v2", encrypt.APIVersion)
+	require.Equal(t, "Encrypt", encrypt.Method)
+	require.Equal(t, "uid-1", encrypt.UID)
+	require.Equal(t, "3", encrypt.KeyID, "key id of the response")
+	require.Equal(t, []string{"vault-request-1"}, encrypt.VaultRequestIDs)
+	require.Equal(t, audit.OutcomeSuccess, encrypt.Outcome)
+	require.Equal(t, codes.OK.String(), encrypt.Code)
+
+	require.Equal(t, "Decrypt", decrypt.Method)
+	require.Equal(t, "uid-2", decrypt.UID)
+	require.Equal(t, "2", decrypt.KeyID, "key id of the request")
+	require.Equal(t, []string{"vault-request-2"}, decrypt.VaultRequestIDs)
+	require.Equal(t, audit.OutcomeFailure, decrypt.Outcome)
+	require.Equal(t, codes.Unavailable.String(), decrypt.Code)
+	require.Equal(t, "vault is sealed, token="+logging.Redacted, decrypt.Error)
+}
+
+func TestKMSMethod(t *testing.T) {
+	apiVersion, method := kmsMethod("/v1beta1.KeyManagementService/Decrypt")
+	require.Equal(t, "v1beta1", apiVersion)
+	require.Equal(t, "Decrypt", method)
+
+	_, method = kmsMethod("/grpc.health.v1.Health/Check")
+	require.Empty(t, method)
+}
@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config configures the KMS gRPC server.
+type Config struct {
+	// DefaultTimeout is applied to RPCs without a deadline, 0 disables the default timeout.
+	DefaultTimeout time.Duration
+
+	// MaxTimeout caps the deadline of every RPC, 0 disables the cap.
+	MaxTimeout time.Duration
+
+	// MaxMessageSize is the maximum size in bytes of received and sent messages, 0 uses the gRPC default.
+	MaxMessageSize int
+
+	// MaxInFlight limits the number of concurrent Encrypt and Decrypt requests, 0 disables the limit.
+	MaxInFlight int
+
+	// MaxQueued is the number of requests waiting for a free slot, before further requests are rejected.
+	MaxQueued int
+
+	// StatusMaxInFlight limits the number of concurrent Status, Version and health requests, 0 disables the limit.
+	StatusMaxInFlight int
+
+	// Provider is the name of the KMS provider served by the server, used as metrics label.
+	Provider string
+
+	// Audit writes an audit entry for every KMS operation, nil disables auditing.
+	Audit *audit.Logger
+
+	// Zeroize wipes the plaintext of requests and responses and the received message buffers after use.
+	Zeroize bool
+}
+
+// ServerOptions returns the server options including the chain of unary interceptors.
+func ServerOptions(cfg Config) []grpc.ServerOption {
+	interceptors := []grpc.UnaryServerInterceptor{
+		ProviderInterceptor(cfg.Provider),
+		TracingInterceptor(),
+	}
+
+	if cfg.Audit != nil {
+		// right after the provider, so that rejected and timed out requests are audited as well
+		interceptors = append(interceptors, AuditInterceptor(cfg.Audit))
+	}
+
+	interceptors = append(interceptors,
+		MetricsInterceptor(),
+		LoggingInterceptor(),
+		TimeoutInterceptor(cfg.DefaultTimeout, cfg.MaxTimeout),
+		LimitInterceptor(
+			NewLimiter(LimiterClassKMS, cfg.MaxInFlight, cfg.MaxQueued),
+			NewLimiter(LimiterClassStatus, cfg.StatusMaxInFlight, cfg.MaxQueued),
+		),
+		RecoveryInterceptor(),
+	)
+
+	if cfg.Zeroize {
+		// outermost, so that the plaintext is only wiped after all other interceptors returned
+		interceptors = append([]grpc.UnaryServerInterceptor{ZeroizeInterceptor()}, interceptors...)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+
+	if cfg.Zeroize {
+		opts = append(opts, grpc.ForceServerCodecV2(newZeroizingCodec()))
+	}
+
+	if cfg.MaxMessageSize > 0 {
+		opts = append(opts,
+			grpc.MaxRecvMsgSize(cfg.MaxMessageSize),
+			grpc.MaxSendMsgSize(cfg.MaxMessageSize),
+		)
+	}
+
+	return opts
+}
+
+// ProviderInterceptor adds the name of the KMS provider to the request context, so that it is used as metrics label.
+func ProviderInterceptor(provider string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if provider == "" {
+			return handler(ctx, req)
+		}
+
+		return handler(metrics.WithProvider(ctx, provider), req)
+	}
+}
+
+// RecoveryInterceptor recovers from panics in handlers and returns codes.Internal instead of crashing the plugin.
+func RecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				metrics.GRPCPanicsTotal.WithLabelValues(metrics.Provider(ctx), info.FullMethod).Inc()
+
+				logging.L(logging.ComponentGRPC).Error("recovered from panic in grpc handler",
+					zap.String("method", info.FullMethod),
+					zap.Any("panic", r),
+					zap.ByteString("stacktrace", debug.Stack()),
+				)
+
+				resp, err = nil, status.Error(codes.Internal, "internal error")
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// LoggingInterceptor logs method, duration and status code of every RPC.
+func LoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		fields := []zap.Field{
+			zap.String("provider", metrics.Provider(ctx)),
+			zap.String("method", info.FullMethod),
+			zap.String("code", code.String()),
+			zap.Duration("duration", time.Since(start)),
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			fields = append(fields, zap.Time("deadline", deadline))
+		}
+
+		if err != nil {
+			logging.L(logging.ComponentGRPC).Warn("grpc request failed", append(fields, zap.Error(err))...)
+		} else {
+			logging.L(logging.ComponentGRPC).Debug("grpc request", fields...)
+		}
+
+		return resp, err
+	}
+}
+
+// MetricsInterceptor records the number, duration and outcome of RPCs and the number of RPCs in flight,
+// including the ones waiting for a slot of the limiter.
+func MetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		provider := metrics.Provider(ctx)
+		apiVersion, operation := methodLabels(info.FullMethod)
+
+		inFlight := metrics.GRPCInFlightRequests.WithLabelValues(provider, info.FullMethod, apiVersion, operation)
+		inFlight.Inc()
+
+		defer inFlight.Dec()
+
+		resp, err := handler(ctx, req)
+
+		labels := []string{provider, info.FullMethod, apiVersion, operation, metrics.Outcome(err), status.Code(err).String()}
+
+		metrics.GRPCRequestsTotal.WithLabelValues(labels...).Inc()
+		metrics.GRPCRequestDurationSeconds.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
+
+// methodLabels returns the api_version and operation labels of a method, e.g. v2 and encrypt
+// for /v2.KeyManagementService/Encrypt. Both are empty for methods of other services, e.g. the grpc health service.
+func methodLabels(fullMethod string) (string, string) {
+	apiVersion, method := kmsMethod(fullMethod)
+	if method == "" {
+		return "", ""
+	}
+
+	return apiVersion, strings.ToLower(method)
+}
+
+// TimeoutInterceptor applies defaultTimeout to RPCs without a deadline and caps every deadline at maxTimeout.
+func TimeoutInterceptor(defaultTimeout, maxTimeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var timeout time.Duration
+
+		if deadline, ok := ctx.Deadline(); ok {
+			// a deadline set by the client is only ever shortened
+			if maxTimeout > 0 && time.Until(deadline) > maxTimeout {
+				timeout = maxTimeout
+			}
+		} else {
+			timeout = defaultTimeout
+
+			if maxTimeout > 0 && (timeout <= 0 || timeout > maxTimeout) {
+				timeout = maxTimeout
+			}
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		return handler(ctx, req)
+	}
+}
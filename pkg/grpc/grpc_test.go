@@ -0,0 +1,258 @@
+package grpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "k8s.io/kms/apis/v2"
+)
+
+type fakeKMS struct {
+	pb.UnimplementedKeyManagementServiceServer
+
+	deadline time.Duration
+	block    chan struct{}
+}
+
+func (f *fakeKMS) Status(ctx context.Context, _ *pb.StatusRequest) (*pb.StatusResponse, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		f.deadline = time.Until(deadline)
+	}
+
+	return &pb.StatusResponse{Version: "v2", Healthz: "ok", KeyId: "1"}, nil
+}
+
+func (f *fakeKMS) Encrypt(_ context.Context, req *pb.EncryptRequest) (*pb.EncryptResponse, error) {
+	switch string(req.GetPlaintext()) {
+	case "panic":
+		panic("boom")
+	case "block":
+		<-f.block
+	}
+
+	return &pb.EncryptResponse{Ciphertext: req.GetPlaintext(), KeyId: "1"}, nil
+}
+
+func startServer(t *testing.T, cfg Config) (pb.KeyManagementServiceClient, *fakeKMS) {
+	t.Helper()
+
+	kms := &fakeKMS{block: make(chan struct{})}
+
+	s := grpc.NewServer(ServerOptions(cfg)...)
+	pb.RegisterKeyManagementServiceServer(s, kms)
+
+	conn := dialBufconn(t, s)
+
+	return pb.NewKeyManagementServiceClient(conn), kms
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	client, _ := startServer(t, Config{})
+
+	before := testutil.ToFloat64(metrics.GRPCPanicsTotal.WithLabelValues(metrics.DefaultProvider, pb.KeyManagementService_Encrypt_FullMethodName))
+
+	_, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("panic")})
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	after := testutil.ToFloat64(metrics.GRPCPanicsTotal.WithLabelValues(metrics.DefaultProvider, pb.KeyManagementService_Encrypt_FullMethodName))
+	require.InDelta(t, before+1, after, 0)
+
+	// the server keeps serving requests after a panic
+	resp, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("plain")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), resp.GetCiphertext())
+}
+
+func TestLoggingInterceptorComponentLevel(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "kms.log")
+
+	l, err := logging.New(logging.Config{
+		Level:           zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		Encoding:        logging.EncodingJSON,
+		File:            logFile,
+		ComponentLevels: map[string]zapcore.Level{logging.ComponentGRPC: zapcore.DebugLevel},
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(zap.ReplaceGlobals(l))
+
+	client, _ := startServer(t, Config{})
+
+	_, err = client.Status(t.Context(), &pb.StatusRequest{})
+	require.NoError(t, err)
+	require.NoError(t, l.Sync())
+
+	logs, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+
+	// the debug access log is enabled by the level of the grpc component
+	require.Contains(t, string(logs), `"logger":"grpc"`)
+	require.Contains(t, string(logs), pb.KeyManagementService_Status_FullMethodName)
+}
+
+func histogramSampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	require.NoError(t, o.(prometheus.Metric).Write(m))
+
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	client, kms := startServer(t, Config{Provider: "secrets"})
+
+	testCases := []struct {
+		method    string
+		call      func() error
+		operation string
+		outcome   string
+		code      codes.Code
+	}{
+		{
+			method: pb.KeyManagementService_Status_FullMethodName,
+			call: func() error {
+				_, err := client.Status(t.Context(), &pb.StatusRequest{})
+
+				return err
+			},
+			operation: "status",
+			outcome:   metrics.OutcomeSuccess,
+			code:      codes.OK,
+		},
+		{
+			method: pb.KeyManagementService_Decrypt_FullMethodName,
+			call: func() error {
+				_, err := client.Decrypt(t.Context(), &pb.DecryptRequest{Ciphertext: []byte("cipher")})
+
+				return err
+			},
+			operation: "decrypt",
+			outcome:   metrics.OutcomeFailure,
+			code:      codes.Unimplemented,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.operation, func(t *testing.T) {
+			labels := []string{"secrets", tc.method, "v2", tc.operation, tc.outcome, tc.code.String()}
+
+			counter := metrics.GRPCRequestsTotal.WithLabelValues(labels...)
+			duration := metrics.GRPCRequestDurationSeconds.WithLabelValues(labels...)
+
+			countBefore, samplesBefore := testutil.ToFloat64(counter), histogramSampleCount(t, duration)
+
+			require.Equal(t, tc.code, status.Code(tc.call()))
+
+			require.InDelta(t, countBefore+1, testutil.ToFloat64(counter), 0)
+			require.Equal(t, samplesBefore+1, histogramSampleCount(t, duration))
+		})
+	}
+
+	// requests are counted as in flight without a limiter
+	inFlight := metrics.GRPCInFlightRequests.WithLabelValues("secrets", pb.KeyManagementService_Encrypt_FullMethodName, "v2", "encrypt")
+
+	errs := make(chan error, 1)
+
+	go func() {
+		_, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("block")})
+		errs <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(inFlight) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	close(kms.block)
+	require.NoError(t, <-errs)
+	require.Zero(t, testutil.ToFloat64(inFlight))
+}
+
+func TestMethodLabels(t *testing.T) {
+	apiVersion, operation := methodLabels(pb.KeyManagementService_Encrypt_FullMethodName)
+	require.Equal(t, "v2", apiVersion)
+	require.Equal(t, "encrypt", operation)
+
+	apiVersion, operation = methodLabels("/grpc.health.v1.Health/Check")
+	require.Empty(t, apiVersion)
+	require.Empty(t, operation)
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	testCases := []struct {
+		name           string
+		defaultTimeout time.Duration
+		maxTimeout     time.Duration
+		clientTimeout  time.Duration
+		exp            time.Duration
+	}{
+		{
+			name:           "default timeout applies without client deadline",
+			defaultTimeout: 5 * time.Second,
+			exp:            5 * time.Second,
+		},
+		{
+			name:           "max timeout caps the default timeout",
+			defaultTimeout: 5 * time.Second,
+			maxTimeout:     2 * time.Second,
+			exp:            2 * time.Second,
+		},
+		{
+			name:          "max timeout caps the client deadline",
+			maxTimeout:    2 * time.Second,
+			clientTimeout: time.Minute,
+			exp:           2 * time.Second,
+		},
+		{
+			name:           "shorter client deadline is kept",
+			defaultTimeout: 5 * time.Second,
+			maxTimeout:     time.Minute,
+			clientTimeout:  time.Second,
+			exp:            time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, kms := startServer(t, Config{DefaultTimeout: tc.defaultTimeout, MaxTimeout: tc.maxTimeout})
+
+			ctx := t.Context()
+
+			if tc.clientTimeout > 0 {
+				var cancel context.CancelFunc
+
+				ctx, cancel = context.WithTimeout(ctx, tc.clientTimeout)
+				defer cancel()
+			}
+
+			_, err := client.Status(ctx, &pb.StatusRequest{})
+			require.NoError(t, err)
+			require.InDelta(t, tc.exp.Seconds(), kms.deadline.Seconds(), 0.5)
+		})
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	client, _ := startServer(t, Config{MaxMessageSize: 1024})
+
+	_, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte(strings.Repeat("x", 2048))})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("plain")})
+	require.NoError(t, err)
+}
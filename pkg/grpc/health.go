@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/probes"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthChecker serves the grpc.health.v1 service and reports the status of every registered
+// service, based on the result of its probers.
+type HealthChecker struct {
+	server *health.Server
+
+	mu       sync.Mutex
+	services map[string][]probes.Prober
+}
+
+// NewHealthChecker returns a health checker without any services.
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		server:   health.NewServer(),
+		services: map[string][]probes.Prober{},
+	}
+}
+
+// AddService adds a service, which is reported as serving once all probers succeed.
+func (h *HealthChecker) AddService(name string, prober ...probes.Prober) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.services[name] = prober
+
+	h.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	h.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Register registers the grpc.health.v1 service with the server.
+func (h *HealthChecker) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.server)
+}
+
+// Check runs the probers of every service and updates their status.
+// The overall status (empty service name) is serving, if all services are serving.
+func (h *HealthChecker) Check(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	overall := healthpb.HealthCheckResponse_SERVING
+
+	for name, prober := range h.services {
+		status := healthpb.HealthCheckResponse_SERVING
+
+		for _, p := range prober {
+			if p == nil {
+				continue
+			}
+
+			if err := p.Health(ctx); err != nil {
+				logging.L(logging.ComponentGRPC).Warn("grpc health check failed", zap.String("service", name), zap.Error(err))
+
+				status = healthpb.HealthCheckResponse_NOT_SERVING
+
+				break
+			}
+		}
+
+		if status != healthpb.HealthCheckResponse_SERVING {
+			overall = status
+		}
+
+		h.server.SetServingStatus(name, status)
+	}
+
+	h.server.SetServingStatus("", overall)
+}
+
+// Run checks the services immediately and then in the specified interval until the context is cancelled.
+// An interval of 0 only checks once. Afterwards all services are reported as not serving.
+func (h *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		h.Check(ctx)
+
+		<-ctx.Done()
+		h.server.Shutdown()
+
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		h.Check(checkCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			h.server.Shutdown()
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
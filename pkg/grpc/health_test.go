@@ -0,0 +1,155 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+	pb "k8s.io/kms/apis/v2"
+)
+
+type toggleProber struct {
+	failing atomic.Bool
+}
+
+func (p *toggleProber) Health(_ context.Context) error {
+	if p.failing.Load() {
+		return errors.New("probe failed")
+	}
+
+	return nil
+}
+
+func dialBufconn(t *testing.T, s *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+
+	go func() {
+		_ = s.Serve(lis)
+	}()
+
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func TestHealthChecker(t *testing.T) {
+	const v1Service = "v1beta1.KeyManagementService"
+
+	v1Prober := &toggleProber{}
+	v2Prober := &toggleProber{}
+
+	hc := NewHealthChecker()
+	hc.AddService(v1Service, v1Prober)
+	hc.AddService(pb.KeyManagementService_ServiceDesc.ServiceName, v2Prober)
+
+	s := grpc.NewServer()
+	hc.Register(s)
+
+	client := healthpb.NewHealthClient(dialBufconn(t, s))
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(t.Context(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+
+		return resp.GetStatus()
+	}
+
+	// services are not serving until they have been checked
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+
+	hc.Check(t.Context())
+
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status(""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status(v1Service))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status(pb.KeyManagementService_ServiceDesc.ServiceName))
+
+	// a failing v1 plugin does not affect the status of v2
+	v1Prober.failing.Store(true)
+	hc.Check(t.Context())
+
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(v1Service))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status(pb.KeyManagementService_ServiceDesc.ServiceName))
+}
+
+func TestHealthCheckerRun(t *testing.T) {
+	prober := &toggleProber{}
+
+	hc := NewHealthChecker()
+	hc.AddService("svc", prober)
+
+	s := grpc.NewServer()
+	hc.Register(s)
+
+	client := healthpb.NewHealthClient(dialBufconn(t, s))
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan struct{})
+
+	go func() {
+		hc.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Check(t.Context(), &healthpb.HealthCheckRequest{Service: "svc"})
+
+		return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	resp, err := client.Check(t.Context(), &healthpb.HealthCheckRequest{Service: "svc"})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+}
+
+func TestReflection(t *testing.T) {
+	s := grpc.NewServer()
+	pb.RegisterKeyManagementServiceServer(s, &fakeKMS{})
+	NewHealthChecker().Register(s)
+	reflection.Register(s)
+
+	stream, err := reflectionpb.NewServerReflectionClient(dialBufconn(t, s)).ServerReflectionInfo(t.Context())
+	require.NoError(t, err)
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	services := []string{}
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+
+	require.Contains(t, services, pb.KeyManagementService_ServiceDesc.ServiceName)
+	require.Contains(t, services, healthpb.Health_ServiceDesc.ServiceName)
+}
@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// LimiterClassKMS is the limiter class of Encrypt and Decrypt requests.
+	LimiterClassKMS = "kms"
+
+	// LimiterClassStatus is the limiter class of all other requests, e.g. Status, Version and health checks.
+	LimiterClassStatus = "status"
+)
+
+// Limiter limits the number of concurrently processed requests.
+// Requests exceeding the limit wait for a free slot, as long as less than maxQueued requests are waiting already.
+type Limiter struct {
+	class     string
+	slots     chan struct{}
+	maxQueued int64
+	queued    atomic.Int64
+}
+
+// NewLimiter returns a limiter allowing maxInFlight concurrent requests, 0 disables the limit.
+func NewLimiter(class string, maxInFlight, maxQueued int) *Limiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+
+	return &Limiter{
+		class:     class,
+		slots:     make(chan struct{}, maxInFlight),
+		maxQueued: int64(maxQueued),
+	}
+}
+
+// acquire blocks until a slot is free and returns a function releasing the slot.
+// If the queue is full, codes.ResourceExhausted is returned immediately.
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	provider := metrics.Provider(ctx)
+
+	release := func() {
+		<-l.slots
+		metrics.GRPCLimiterInFlightRequests.WithLabelValues(provider, l.class).Dec()
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		metrics.GRPCLimiterInFlightRequests.WithLabelValues(provider, l.class).Inc()
+
+		return release, nil
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		metrics.GRPCRejectedRequestsTotal.WithLabelValues(provider, l.class).Inc()
+
+		return nil, status.Errorf(codes.ResourceExhausted, "too many concurrent %s requests", l.class)
+	}
+
+	metrics.GRPCQueuedRequests.WithLabelValues(provider, l.class).Inc()
+
+	defer func() {
+		l.queued.Add(-1)
+		metrics.GRPCQueuedRequests.WithLabelValues(provider, l.class).Dec()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		metrics.GRPCLimiterInFlightRequests.WithLabelValues(provider, l.class).Inc()
+
+		return release, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// LimitInterceptor limits Encrypt and Decrypt requests with the kms limiter and all other requests with the status limiter,
+// so that status and health checks are not starved by bulk operations. A nil limiter does not limit requests.
+func LimitInterceptor(kms, statusLimiter *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		l := statusLimiter
+		if isKMSOperation(info.FullMethod) {
+			l = kms
+		}
+
+		if l == nil {
+			return handler(ctx, req)
+		}
+
+		release, err := l.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		defer release()
+
+		return handler(ctx, req)
+	}
+}
+
+func isKMSOperation(fullMethod string) bool {
+	return strings.HasSuffix(fullMethod, "/Encrypt") || strings.HasSuffix(fullMethod, "/Decrypt")
+}
@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "k8s.io/kms/apis/v2"
+)
+
+func TestLimitInterceptor(t *testing.T) {
+	client, kms := startServer(t, Config{MaxInFlight: 1, MaxQueued: 1, StatusMaxInFlight: 1})
+
+	inFlight := metrics.GRPCLimiterInFlightRequests.WithLabelValues(metrics.DefaultProvider, LimiterClassKMS)
+	queued := metrics.GRPCQueuedRequests.WithLabelValues(metrics.DefaultProvider, LimiterClassKMS)
+	rejected := metrics.GRPCRejectedRequestsTotal.WithLabelValues(metrics.DefaultProvider, LimiterClassKMS)
+
+	rejectedBefore := testutil.ToFloat64(rejected)
+
+	errs := make(chan error, 2)
+
+	// the first request occupies the only slot, the second one waits in the queue
+	for range 2 {
+		go func() {
+			_, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("block")})
+			errs <- err
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(inFlight) == 1 && testutil.ToFloat64(queued) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// the queue is full
+	_, err := client.Decrypt(t.Context(), &pb.DecryptRequest{Ciphertext: []byte("cipher")})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.InDelta(t, rejectedBefore+1, testutil.ToFloat64(rejected), 0)
+
+	// status requests use a separate limit and are not starved by kms requests
+	_, err = client.Status(t.Context(), &pb.StatusRequest{})
+	require.NoError(t, err)
+
+	close(kms.block)
+
+	for range 2 {
+		require.NoError(t, <-errs)
+	}
+
+	require.Zero(t, testutil.ToFloat64(inFlight))
+	require.Zero(t, testutil.ToFloat64(queued))
+}
+
+func TestLimiterRespectsDeadline(t *testing.T) {
+	l := NewLimiter("test", 1, 1)
+
+	release, err := l.acquire(t.Context())
+	require.NoError(t, err)
+
+	defer release()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = l.acquire(ctx)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestNewLimiterDisabled(t *testing.T) {
+	require.Nil(t, NewLimiter(LimiterClassKMS, 0, 10))
+}
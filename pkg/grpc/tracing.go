@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TracingInterceptor starts a server span for every RPC, continuing a trace propagated in the request metadata.
+// The uid of v2 requests is added as kms.uid attribute.
+func TracingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+		method := strings.TrimPrefix(info.FullMethod, "/")
+		attrs := []attribute.KeyValue{
+			semconv.RPCSystemNameGRPC,
+			semconv.RPCMethod(method),
+			attribute.String("kms.provider", metrics.Provider(ctx)),
+		}
+
+		if uid := stringField(req, "uid"); uid != "" {
+			attrs = append(attrs, attribute.String("kms.uid", uid))
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+
+		span.SetAttributes(semconv.RPCResponseStatusCode(status.Code(err).String()))
+
+		if err != nil {
+			span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+		}
+
+		return resp, err
+	}
+}
+
+// metadataCarrier reads propagated trace context from gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	pb "k8s.io/kms/apis/v2"
+)
+
+// newTestTracerProvider installs a tracer provider recording all spans in memory.
+func newTestTracerProvider(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	global, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(global)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	return exporter
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+
+	return attrs
+}
+
+func TestTracingInterceptor(t *testing.T) {
+	exporter := newTestTracerProvider(t)
+
+	s := grpc.NewServer(ServerOptions(Config{Provider: "tenant-a"})...)
+	pb.RegisterKeyManagementServiceServer(s, &auditKMS{})
+
+	client := pb.NewKeyManagementServiceClient(dialBufconn(t, s))
+
+	// the trace of the caller is continued
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	_, err = client.Encrypt(ctx, &pb.EncryptRequest{Plaintext: []byte("dek"), Uid: "uid-1"})
+	require.NoError(t, err)
+
+	_, err = client.Decrypt(t.Context(), &pb.DecryptRequest{Ciphertext: []byte("cipher"), Uid: "uid-2"})
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	encrypt, decrypt := spans[0], spans[1]
+
+	require.Equal(t, "v2.KeyManagementService/Encrypt", encrypt.Name)
+	require.Equal(t, trace.SpanKindServer, encrypt.SpanKind)
+	require.Equal(t, traceID, encrypt.SpanContext.TraceID())
+	require.True(t, encrypt.Parent.IsRemote())
+	require.Equal(t, "uid-1", spanAttributes(encrypt)["kms.uid"])
+	require.Equal(t, "tenant-a", spanAttributes(encrypt)["kms.provider"])
+	require.Equal(t, "OK", spanAttributes(encrypt)["rpc.response.status_code"])
+
+	require.Equal(t, "uid-2", spanAttributes(decrypt)["kms.uid"])
+	require.Equal(t, "Unavailable", spanAttributes(decrypt)["rpc.response.status_code"])
+	require.Equal(t, otelcodes.Error, decrypt.Status.Code)
+	require.False(t, decrypt.Parent.IsValid())
+}
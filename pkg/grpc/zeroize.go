@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	grpcproto "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// plaintextFields are the names of the fields holding plaintext DEKs in the v1beta1 and v2 KMS messages.
+var plaintextFields = []protoreflect.Name{"plain", "plaintext"}
+
+// ZeroizeInterceptor wipes the plaintext of requests once the handler returned.
+func ZeroizeInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if msg, ok := req.(proto.Message); ok {
+			defer wipePlaintext(msg)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// zeroizingCodec is the proto codec, that wipes the received message buffers after unmarshalling
+// and the plaintext of responses after marshalling and sending.
+type zeroizingCodec struct {
+	encoding.CodecV2
+}
+
+func newZeroizingCodec() encoding.CodecV2 {
+	return zeroizingCodec{CodecV2: encoding.GetCodecV2(grpcproto.Name)}
+}
+
+func (c zeroizingCodec) Marshal(v any) (mem.BufferSlice, error) {
+	msg, ok := v.(proto.Message)
+	if !ok || !hasPlaintext(msg) {
+		return c.CodecV2.Marshal(v)
+	}
+
+	// responses are marshalled after all interceptors returned, so this is the last use of the plaintext
+	defer wipePlaintext(msg)
+
+	// buffers below the pooling threshold are not released once sent, so the buffer is allocated larger
+	// for gRPC to hand it to wipingPool after the response was written
+	capacity := max(proto.Size(msg), 1)
+	for mem.IsBelowBufferPoolingThreshold(capacity) {
+		capacity *= 2
+	}
+
+	data, err := proto.MarshalOptions{}.MarshalAppend(make([]byte, 0, capacity), msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return mem.BufferSlice{mem.NewBuffer(&data, wipingPool{})}, nil
+}
+
+func (c zeroizingCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	// the received buffers are read-only and may be shared, so the message is unmarshalled from an owned copy
+	// that is wiped afterwards, proto.Unmarshal copies the bytes fields
+	owned := data.Materialize()
+	defer secure.Wipe(owned)
+
+	return c.CodecV2.Unmarshal(mem.BufferSlice{mem.SliceBuffer(owned)}, v)
+}
+
+// wipingPool wipes marshalled responses once gRPC released them after sending, instead of reusing them.
+type wipingPool struct{}
+
+func (wipingPool) Get(length int) *[]byte {
+	b := make([]byte, length)
+
+	return &b
+}
+
+func (wipingPool) Put(b *[]byte) {
+	secure.Wipe((*b)[:cap(*b)])
+}
+
+// hasPlaintext reports whether a KMS message holds a plaintext.
+func hasPlaintext(msg proto.Message) bool {
+	m := msg.ProtoReflect()
+
+	for _, name := range plaintextFields {
+		fd := m.Descriptor().Fields().ByName(name)
+		if fd != nil && fd.Kind() == protoreflect.BytesKind && !fd.IsList() && len(m.Get(fd).Bytes()) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wipePlaintext overwrites the plaintext fields of KMS messages with zeros.
+func wipePlaintext(msg proto.Message) {
+	m := msg.ProtoReflect()
+
+	for _, name := range plaintextFields {
+		fd := m.Descriptor().Fields().ByName(name)
+		if fd == nil || fd.Kind() != protoreflect.BytesKind || fd.IsList() {
+			continue
+		}
+
+		secure.Wipe(m.Get(fd).Bytes())
+	}
+}
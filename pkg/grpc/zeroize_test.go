@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/kms/apis/v1beta1"
+	pb "k8s.io/kms/apis/v2"
+)
+
+type zeroizeKMS struct {
+	pb.UnimplementedKeyManagementServiceServer
+
+	encryptReq  *pb.EncryptRequest
+	decryptResp *pb.DecryptResponse
+}
+
+func (z *zeroizeKMS) Encrypt(_ context.Context, req *pb.EncryptRequest) (*pb.EncryptResponse, error) {
+	z.encryptReq = req
+
+	return &pb.EncryptResponse{Ciphertext: []byte("cipher"), KeyId: "1"}, nil
+}
+
+func (z *zeroizeKMS) Decrypt(_ context.Context, _ *pb.DecryptRequest) (*pb.DecryptResponse, error) {
+	z.decryptResp = &pb.DecryptResponse{Plaintext: []byte("dek")}
+
+	return z.decryptResp, nil
+}
+
+func TestZeroize(t *testing.T) {
+	kms := &zeroizeKMS{}
+
+	s := grpc.NewServer(ServerOptions(Config{Zeroize: true})...)
+	pb.RegisterKeyManagementServiceServer(s, kms)
+
+	client := pb.NewKeyManagementServiceClient(dialBufconn(t, s))
+
+	_, err := client.Encrypt(t.Context(), &pb.EncryptRequest{Plaintext: []byte("dek"), Uid: "1"})
+	require.NoError(t, err)
+	require.Len(t, kms.encryptReq.GetPlaintext(), len("dek"))
+	require.True(t, secure.IsZero(kms.encryptReq.GetPlaintext()), "request plaintext must be wiped")
+
+	resp, err := client.Decrypt(t.Context(), &pb.DecryptRequest{Ciphertext: []byte("cipher"), Uid: "1"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("dek"), resp.GetPlaintext(), "the client receives the plaintext")
+	require.True(t, secure.IsZero(kms.decryptResp.GetPlaintext()), "response plaintext must be wiped")
+}
+
+func TestZeroizingCodec(t *testing.T) {
+	codec := newZeroizingCodec()
+
+	raw, err := proto.Marshal(&v1.EncryptRequest{Version: "v1beta1", Plain: []byte("dek")})
+	require.NoError(t, err)
+
+	req := &v1.EncryptRequest{}
+
+	received := slices.Clone(raw)
+
+	require.NoError(t, codec.Unmarshal(mem.BufferSlice{mem.SliceBuffer(raw)}, req))
+	require.Equal(t, []byte("dek"), req.GetPlain())
+	require.Equal(t, received, raw, "read-only received buffers must not be modified")
+
+	resp := &v1.DecryptResponse{Plain: []byte("dek")}
+
+	data, err := codec.Marshal(resp)
+	require.NoError(t, err)
+	require.True(t, secure.IsZero(resp.GetPlain()), "response plaintext must be wiped")
+
+	decoded := &v1.DecryptResponse{}
+	require.NoError(t, proto.Unmarshal(data.Materialize(), decoded))
+	require.Equal(t, []byte("dek"), decoded.GetPlain())
+
+	// gRPC frees the buffers once the response was sent
+	marshalled := data[0].ReadOnlyData()
+
+	data.Free()
+	require.True(t, secure.IsZero(marshalled), "marshalled response must be wiped once sent")
+
+	// messages without plaintext use the proto codec
+	data, err = codec.Marshal(&v1.EncryptResponse{Cipher: []byte("cipher")})
+	require.NoError(t, err)
+
+	data.Free()
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// BearerTokenMiddleware only passes requests presenting the token of tokenFile in the Authorization header.
+// The file is read on every request, so that a rotated token is accepted without a restart.
+func BearerTokenMiddleware(tokenFile string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil || len(bytes.TrimSpace(token)) == 0 {
+			logging.L(logging.ComponentHTTP).Error("failed to read bearer token file", zap.String("file", tokenFile), zap.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), bytes.TrimSpace(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// ClientCertMiddleware only passes requests of clients that presented a verified TLS client certificate.
+func ClientCertMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerTokenMiddleware(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("first\n"), 0o600))
+
+	handler := BearerTokenMiddleware(tokenFile, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	status := func(header string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, status("Bearer first"))
+	require.Equal(t, http.StatusUnauthorized, status("Bearer second"))
+	require.Equal(t, http.StatusUnauthorized, status("Basic first"))
+	require.Equal(t, http.StatusUnauthorized, status(""))
+
+	// a rotated token is accepted without a restart
+	require.NoError(t, os.WriteFile(tokenFile, []byte("second"), 0o600))
+	require.Equal(t, http.StatusOK, status("Bearer second"))
+	require.Equal(t, http.StatusUnauthorized, status("Bearer first"))
+
+	require.NoError(t, os.Remove(tokenFile))
+	require.Equal(t, http.StatusInternalServerError, status("Bearer second"))
+}
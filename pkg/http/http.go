@@ -6,6 +6,12 @@ import (
 	"time"
 
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const requestTimeout = 10 * time.Second
@@ -13,19 +19,61 @@ const requestTimeout = 10 * time.Second
 // RoundTripper is a custom HTTP RoundTripper.
 type RoundTripper struct {
 	Transport http.RoundTripper
+
+	// Limiter throttles requests after Vault responded with 429 Too Many Requests, nil disables throttling.
+	Limiter *AdaptiveLimiter
 }
 
+// RoundTrip sends the request as a child span of the span in the request context
+// and propagates the trace context to Vault in the request headers.
+// Spans and metrics use the templated path, which contains no mount, key or user names.
 func (rd *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, operation := vaultPath(req.URL.Path)
+
+	ctx, span := tracing.Tracer().Start(req.Context(), "vault "+req.Method+" "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLTemplate(path),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	if rd.Limiter != nil {
+		if err := rd.Limiter.Wait(ctx); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+	}
+
+	// a round tripper must not modify the original request
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	startTime := time.Now()
 
 	resp, err := rd.Transport.RoundTrip(req)
 
+	if rd.Limiter != nil {
+		rd.Limiter.Observe(resp)
+	}
+
 	status := "error"
 	if resp != nil {
 		status = strconv.Itoa(resp.StatusCode)
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	} else if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
 	}
 
-	metrics.VaultRequestsDurationSeconds.WithLabelValues(req.Method, req.URL.Path, status).Observe(time.Since(startTime).Seconds())
+	metrics.VaultRequestsDurationSeconds.WithLabelValues(metrics.Provider(req.Context()), req.Method, path, operation, status).Observe(time.Since(startTime).Seconds())
 
 	return resp, err
 }
@@ -52,6 +100,6 @@ func New() *http.Client {
 func NewWithTransport(transport http.RoundTripper) *http.Client {
 	return &http.Client{
 		Timeout:   requestTimeout,
-		Transport: &RoundTripper{Transport: transport},
+		Transport: &RoundTripper{Transport: transport, Limiter: NewAdaptiveLimiter(0)},
 	}
 }
@@ -10,6 +10,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -49,6 +55,16 @@ func gatherVaultRequestMetric(t *testing.T, method, path, status string) *dto.Me
 	return nil
 }
 
+func hasLabel(metric *dto.Metric, name, value string) bool {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name && label.GetValue() == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func TestRoundTripRecordsStatusCode(t *testing.T) {
 	metrics.VaultRequestsDurationSeconds.Reset()
 
@@ -70,8 +86,9 @@ func TestRoundTripRecordsStatusCode(t *testing.T) {
 		require.NoError(t, resp.Body.Close())
 	})
 
-	metric := gatherVaultRequestMetric(t, http.MethodPost, "/v1/transit/encrypt/kms", "201")
+	metric := gatherVaultRequestMetric(t, http.MethodPost, "/v1/{mount}/encrypt/{key}", "201")
 	require.EqualValues(t, 1, metric.GetHistogram().GetSampleCount())
+	require.True(t, hasLabel(metric, "operation", "encrypt"))
 }
 
 func TestRoundTripRecordsTransportErrors(t *testing.T) {
@@ -95,6 +112,59 @@ func TestRoundTripRecordsTransportErrors(t *testing.T) {
 		})
 	}
 
-	metric := gatherVaultRequestMetric(t, http.MethodGet, "/v1/transit/decrypt/kms", "error")
+	metric := gatherVaultRequestMetric(t, http.MethodGet, "/v1/{mount}/decrypt/{key}", "error")
 	require.EqualValues(t, 1, metric.GetHistogram().GetSampleCount())
 }
+
+func TestRoundTripTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	global, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(global)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	var traceparent string
+
+	client := &RoundTripper{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			traceparent = req.Header.Get("traceparent")
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(http.NoBody),
+			}, nil
+		}),
+	}
+
+	ctx, parent := otel.Tracer("test").Start(t.Context(), "v2.KeyManagementService/Encrypt")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "https://vault.example:8200/v1/transit/encrypt/kms", nil)
+	require.NoError(t, err)
+
+	resp, err := client.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	parent.End()
+
+	require.Empty(t, req.Header.Get("traceparent"), "the original request is not modified")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	span := spans[0]
+	require.Equal(t, "vault PUT /v1/{mount}/encrypt/{key}", span.Name)
+	require.Equal(t, trace.SpanKindClient, span.SpanKind)
+	require.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	require.Contains(t, span.Attributes, attribute.String("server.address", "vault.example"))
+	require.Contains(t, span.Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+
+	// vault receives the span of the request as parent
+	require.Equal(t, "00-"+span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String()+"-01", traceparent)
+}
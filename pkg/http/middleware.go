@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"go.uber.org/zap"
 )
 
@@ -13,7 +14,7 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		next(w, r)
 
-		zap.L().Debug("received request",
+		logging.L(logging.ComponentHTTP).Debug("received request",
 			zap.String("method", r.Method),
 			zap.String("path", r.RequestURI),
 			zap.String("duration", time.Since(start).String()),
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"strings"
+)
+
+// Values of the operation label of Vault requests.
+const (
+	operationEncrypt     = "encrypt"
+	operationDecrypt     = "decrypt"
+	operationReadKey     = "read_key"
+	operationLogin       = "login"
+	operationTokenLookup = "token_lookup"
+	operationTokenRenew  = "token_renew"
+	operationTokenRevoke = "token_revoke"
+	operationHealth      = "health"
+	operationMount       = "mount"
+	operationOther       = "other"
+)
+
+const apiPrefix = "/v1/"
+
+// tokenPaths are the token endpoints of Vault, which contain no identifiers.
+var tokenPaths = map[string]string{
+	"auth/token/lookup-self": operationTokenLookup,
+	"auth/token/renew-self":  operationTokenRenew,
+	"auth/token/revoke-self": operationTokenRevoke,
+}
+
+// vaultPath returns the path of a Vault request with mount, key and user names replaced by placeholders and the
+// operation of the request. Mount paths may contain slashes, so the known endpoints are matched from the end
+// of the path. Unknown paths are reported as other, so that the labels of the request metrics stay bounded.
+func vaultPath(path string) (string, string) {
+	p, ok := strings.CutPrefix(path, apiPrefix)
+	if !ok {
+		return operationOther, operationOther
+	}
+
+	if op, ok := tokenPaths[p]; ok {
+		return path, op
+	}
+
+	if p == "sys/health" {
+		return path, operationHealth
+	}
+
+	segments := strings.Split(p, "/")
+	n := len(segments)
+
+	switch {
+	case segments[0] == "sys" && n > 2 && segments[1] == "mounts":
+		return apiPrefix + "sys/mounts/{mount}", operationMount
+	case segments[0] == "auth" && n > 2 && segments[n-1] == "login":
+		return apiPrefix + "auth/{mount}/login", operationLogin
+	case segments[0] == "auth" && n > 3 && segments[n-2] == "login":
+		return apiPrefix + "auth/{mount}/login/{user}", operationLogin
+	case segments[0] == "auth":
+		return operationOther, operationOther
+	case n > 2 && segments[n-2] == "encrypt":
+		return apiPrefix + "{mount}/encrypt/{key}", operationEncrypt
+	case n > 2 && segments[n-2] == "decrypt":
+		return apiPrefix + "{mount}/decrypt/{key}", operationDecrypt
+	case n > 2 && segments[n-2] == "keys":
+		return apiPrefix + "{mount}/keys/{key}", operationReadKey
+	}
+
+	return operationOther, operationOther
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultPath(t *testing.T) {
+	testCases := []struct {
+		path      string
+		template  string
+		operation string
+	}{
+		{"/v1/transit/encrypt/kms", "/v1/{mount}/encrypt/{key}", "encrypt"},
+		{"/v1/kubernetes/transit/decrypt/kms", "/v1/{mount}/decrypt/{key}", "decrypt"},
+		{"/v1/transit/keys/kms", "/v1/{mount}/keys/{key}", "read_key"},
+		{"/v1/auth/approle/login", "/v1/auth/{mount}/login", "login"},
+		{"/v1/auth/clusters/prod/cert/login", "/v1/auth/{mount}/login", "login"},
+		{"/v1/auth/userpass/login/alice", "/v1/auth/{mount}/login/{user}", "login"},
+		{"/v1/auth/token/lookup-self", "/v1/auth/token/lookup-self", "token_lookup"},
+		{"/v1/auth/token/renew-self", "/v1/auth/token/renew-self", "token_renew"},
+		{"/v1/auth/token/lookup/s.secret", "other", "other"},
+		{"/v1/sys/health", "/v1/sys/health", "health"},
+		{"/v1/sys/mounts/transit", "/v1/sys/mounts/{mount}", "mount"},
+		{"/v1/secret/data/app", "other", "other"},
+		{"/healthz", "other", "other"},
+	}
+
+	for _, tc := range testCases {
+		template, operation := vaultPath(tc.path)
+		require.Equal(t, tc.template, template, tc.path)
+		require.Equal(t, tc.operation, operation, tc.path)
+	}
+}
@@ -0,0 +1,230 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// headerRateLimitReset is set by Vault resource quotas to the number of seconds until the quota resets.
+	headerRateLimitReset = "X-Ratelimit-Reset"
+
+	// defaultRetryAfter is used if a 429 response has neither Retry-After nor X-Ratelimit-Reset set.
+	defaultRetryAfter = time.Second
+
+	// maxRetryAfter caps the time all requests are held back after a 429 response.
+	maxRetryAfter = time.Minute
+
+	// minRate is the lowest request rate the limiter shrinks to.
+	minRate = 1.0
+
+	// decreaseFactor is applied to the request rate on every 429 response.
+	decreaseFactor = 0.5
+)
+
+// AdaptiveLimiter is a client side token bucket, that shrinks whenever Vault responds with 429 Too Many Requests
+// and recovers additively with every successful response.
+// After a 429 response, all requests are held back until the time indicated by Retry-After or X-Ratelimit-Reset.
+type AdaptiveLimiter struct {
+	mu sync.Mutex
+
+	// maxRate is the configured request rate per second, 0 means unlimited.
+	maxRate float64
+
+	// recoveredRate is the rate at which the limiter is lifted again, when maxRate is unlimited.
+	recoveredRate float64
+
+	limiter      *rate.Limiter
+	blockedUntil time.Time
+
+	// used to estimate the current request rate, when the limiter is unlimited
+	windowStart time.Time
+	windowCount int
+	lastRate    float64
+
+	now func() time.Time
+}
+
+// NewAdaptiveLimiter returns an adaptive limiter starting at maxRate requests per second, 0 starts unlimited.
+func NewAdaptiveLimiter(maxRate float64) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		maxRate: math.Max(maxRate, 0),
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		now:     time.Now,
+	}
+
+	if l.maxRate > 0 {
+		l.setRate(l.maxRate)
+	} else {
+		metrics.VaultClientRateLimit.Set(0)
+	}
+
+	return l
+}
+
+// Rate returns the current request rate per second, 0 means unlimited.
+func (l *AdaptiveLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.currentRate()
+}
+
+// Wait blocks until a request is allowed to be sent.
+func (l *AdaptiveLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	wait := l.blockedUntil.Sub(l.now())
+	limiter := l.limiter
+	l.countRequest()
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return limiter.Wait(ctx)
+}
+
+// Observe adjusts the request rate based on the response.
+func (l *AdaptiveLimiter) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		l.recover()
+
+		return
+	}
+
+	metrics.VaultRateLimitedRequestsTotal.Inc()
+
+	retryAfter := parseRetryAfter(resp.Header, l.now())
+	if until := l.now().Add(retryAfter); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+
+	current := l.currentRate()
+	if current == 0 {
+		// unlimited so far, start from the estimated rate that caused the 429
+		current = math.Max(l.estimatedRate(), minRate)
+		l.recoveredRate = current
+	}
+
+	l.setRate(math.Max(current*decreaseFactor, minRate))
+
+	logging.L(logging.ComponentHTTP).Warn("vault rate limit exceeded, throttling requests",
+		zap.Duration("retry_after", retryAfter),
+		zap.Float64("rate", l.currentRate()),
+	)
+}
+
+func (l *AdaptiveLimiter) recover() {
+	current := l.currentRate()
+	if current == 0 {
+		return
+	}
+
+	target := l.maxRate
+	if target == 0 {
+		target = l.recoveredRate
+	}
+
+	if current+1 >= target {
+		if l.maxRate == 0 {
+			l.limiter.SetLimit(rate.Inf)
+			metrics.VaultClientRateLimit.Set(0)
+
+			logging.L(logging.ComponentHTTP).Info("vault request rate recovered")
+
+			return
+		}
+
+		l.setRate(l.maxRate)
+
+		return
+	}
+
+	l.setRate(current + 1)
+}
+
+func (l *AdaptiveLimiter) setRate(r float64) {
+	l.limiter.SetLimit(rate.Limit(r))
+	l.limiter.SetBurst(int(math.Max(math.Ceil(r), 1)))
+
+	metrics.VaultClientRateLimit.Set(r)
+}
+
+func (l *AdaptiveLimiter) currentRate() float64 {
+	if l.limiter.Limit() == rate.Inf {
+		return 0
+	}
+
+	return float64(l.limiter.Limit())
+}
+
+func (l *AdaptiveLimiter) countRequest() {
+	now := l.now()
+
+	if elapsed := now.Sub(l.windowStart); elapsed >= time.Second {
+		l.lastRate = float64(l.windowCount) / elapsed.Seconds()
+		l.windowStart = now
+		l.windowCount = 0
+	}
+
+	l.windowCount++
+}
+
+func (l *AdaptiveLimiter) estimatedRate() float64 {
+	elapsed := l.now().Sub(l.windowStart).Seconds()
+	if elapsed <= 0 {
+		return l.lastRate
+	}
+
+	return math.Max(l.lastRate, float64(l.windowCount)/math.Max(elapsed, 1))
+}
+
+// parseRetryAfter returns the duration to wait after a 429 response based on the Retry-After header
+// (either seconds or a HTTP date) or Vaults X-Ratelimit-Reset header.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	var d time.Duration
+
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			d = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			d = t.Sub(now)
+		}
+	}
+
+	if d <= 0 {
+		if seconds, err := strconv.Atoi(header.Get(headerRateLimitReset)); err == nil {
+			d = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if d <= 0 {
+		return defaultRetryAfter
+	}
+
+	return min(d, maxRetryAfter)
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func tooManyRequests(header http.Header) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     header,
+		Body:       io.NopCloser(http.NoBody),
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		header http.Header
+		exp    time.Duration
+	}{
+		{
+			name:   "retry after seconds",
+			header: http.Header{"Retry-After": {"3"}},
+			exp:    3 * time.Second,
+		},
+		{
+			name:   "retry after http date",
+			header: http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}},
+			exp:    5 * time.Second,
+		},
+		{
+			name:   "vault rate limit reset",
+			header: http.Header{"X-Ratelimit-Reset": {"2"}},
+			exp:    2 * time.Second,
+		},
+		{
+			name:   "retry after takes precedence",
+			header: http.Header{"Retry-After": {"4"}, "X-Ratelimit-Reset": {"2"}},
+			exp:    4 * time.Second,
+		},
+		{
+			name:   "capped",
+			header: http.Header{"Retry-After": {"3600"}},
+			exp:    maxRetryAfter,
+		},
+		{
+			name:   "invalid header",
+			header: http.Header{"Retry-After": {"soon"}},
+			exp:    defaultRetryAfter,
+		},
+		{
+			name:   "no header",
+			header: http.Header{},
+			exp:    defaultRetryAfter,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.exp, parseRetryAfter(tc.header, now))
+		})
+	}
+}
+
+func TestAdaptiveLimiterShrinksAndRecovers(t *testing.T) {
+	l := NewAdaptiveLimiter(10)
+	require.InDelta(t, 10, l.Rate(), 0)
+	require.InDelta(t, 10, testutil.ToFloat64(metrics.VaultClientRateLimit), 0)
+
+	before := testutil.ToFloat64(metrics.VaultRateLimitedRequestsTotal)
+
+	l.Observe(tooManyRequests(http.Header{}))
+	require.InDelta(t, 5, l.Rate(), 0)
+
+	l.Observe(tooManyRequests(http.Header{}))
+	l.Observe(tooManyRequests(http.Header{}))
+	l.Observe(tooManyRequests(http.Header{}))
+	require.InDelta(t, minRate, l.Rate(), 0)
+	require.InDelta(t, before+4, testutil.ToFloat64(metrics.VaultRateLimitedRequestsTotal), 0)
+
+	for range 20 {
+		l.Observe(&http.Response{StatusCode: http.StatusOK})
+	}
+
+	require.InDelta(t, 10, l.Rate(), 0)
+	require.InDelta(t, 10, testutil.ToFloat64(metrics.VaultClientRateLimit), 0)
+}
+
+func TestAdaptiveLimiterUnlimited(t *testing.T) {
+	now := time.Now()
+
+	l := NewAdaptiveLimiter(0)
+	l.now = func() time.Time { return now }
+
+	// 8 requests per second
+	for range 8 {
+		require.NoError(t, l.Wait(t.Context()))
+	}
+
+	now = now.Add(time.Second)
+	require.NoError(t, l.Wait(t.Context()))
+	require.Zero(t, l.Rate())
+
+	l.Observe(tooManyRequests(http.Header{"Retry-After": {"0"}}))
+	require.InDelta(t, 4, l.Rate(), 0)
+
+	for range 4 {
+		l.Observe(&http.Response{StatusCode: http.StatusOK})
+	}
+
+	// unlimited again once the rate, that caused the 429, is reached
+	require.Zero(t, l.Rate())
+	require.Zero(t, testutil.ToFloat64(metrics.VaultClientRateLimit))
+}
+
+func TestRoundTripHonorsRetryAfter(t *testing.T) {
+	calls := 0
+
+	rt := &RoundTripper{
+		Limiter: NewAdaptiveLimiter(0),
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+
+			if calls == 1 {
+				return tooManyRequests(http.Header{"X-Ratelimit-Reset": {"1"}}), nil
+			}
+
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(http.NoBody)}, nil
+		}),
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, "https://vault.example/v1/transit/encrypt/kms", nil)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// requests are held back until the rate limit resets
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = rt.RoundTrip(req.WithContext(ctx))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, calls)
+
+	start := time.Now()
+
+	resp, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+}
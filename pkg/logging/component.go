@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Components with their own logger, whose level can be set with ParseComponentLevels.
+const (
+	ComponentVault  = "vault"
+	ComponentPlugin = "plugin"
+	ComponentProbes = "probes"
+	ComponentHTTP   = "http"
+	ComponentGRPC   = "grpc"
+	ComponentSocket = "socket"
+	ComponentCerts  = "certs"
+	ComponentAudit  = "audit"
+	ComponentAdmin  = "admin"
+	ComponentEvents = "events"
+)
+
+var components = []string{
+	ComponentVault, ComponentPlugin, ComponentProbes, ComponentHTTP, ComponentGRPC, ComponentSocket, ComponentCerts, ComponentAudit, ComponentAdmin,
+	ComponentEvents,
+}
+
+// L returns the global logger of a component.
+func L(component string) *zap.Logger {
+	return zap.L().Named(component)
+}
+
+// ParseComponentLevels parses a comma separated list of component levels, e.g. "vault=debug,http=warn".
+func ParseComponentLevels(s string) (map[string]zapcore.Level, error) {
+	levels := map[string]zapcore.Level{}
+
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l == "" {
+			continue
+		}
+
+		component, level, ok := strings.Cut(l, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid component level %q, expected <component>=<level>", l)
+		}
+
+		component = strings.TrimSpace(component)
+		if !slices.Contains(components, component) {
+			return nil, fmt.Errorf("invalid component %q. Supported: %s", component, strings.Join(components, ", "))
+		}
+
+		lvl, err := zapcore.ParseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level of component %s: %w", component, err)
+		}
+
+		levels[component] = lvl
+	}
+
+	return levels, nil
+}
+
+// componentLevels is the level of loggers by their component.
+type componentLevels struct {
+	level  zap.AtomicLevel
+	levels map[string]zapcore.Level
+}
+
+func newComponentLevels(level zap.AtomicLevel, levels map[string]zapcore.Level) *componentLevels {
+	return &componentLevels{level: level, levels: levels}
+}
+
+// enabled reports whether the logger with the given name logs at the level.
+func (c *componentLevels) enabled(loggerName string, lvl zapcore.Level) bool {
+	component, _, _ := strings.Cut(loggerName, ".")
+
+	if l, ok := c.levels[component]; ok {
+		return l.Enabled(lvl)
+	}
+
+	return c.level.Enabled(lvl)
+}
+
+// minEnabled reports whether any logger logs at the level.
+func (c *componentLevels) minEnabled(lvl zapcore.Level) bool {
+	if c.level.Enabled(lvl) {
+		return true
+	}
+
+	for _, l := range c.levels {
+		if l.Enabled(lvl) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// componentCore drops entries below the level of the component of their logger.
+type componentCore struct {
+	zapcore.Core
+
+	levels *componentLevels
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *componentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.enabled(ent.LoggerName, ent.Level) {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
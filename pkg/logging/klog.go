@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var bufferPool = buffer.NewPool()
+
+// klogEncoder writes entries in the text format of klog used by the Kubernetes control plane, e.g.
+//
+//	I1019 12:00:00.123456       1 plugin.go:42] "starting kms plugin" logger="vault" socket="/opt/kms/vaultkms.socket"
+type klogEncoder struct {
+	// context holds the fields added with With, which are written sorted by key.
+	*zapcore.MapObjectEncoder
+
+	pid int
+}
+
+func newKlogEncoder() *klogEncoder {
+	return &klogEncoder{MapObjectEncoder: zapcore.NewMapObjectEncoder(), pid: os.Getpid()}
+}
+
+func (e *klogEncoder) Clone() zapcore.Encoder {
+	clone := newKlogEncoder()
+	maps.Copy(clone.Fields, e.Fields)
+
+	return clone
+}
+
+func (e *klogEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	buf.AppendByte(severity(ent.Level))
+	buf.AppendString(ent.Time.Format("0102 15:04:05.000000"))
+	fmt.Fprintf(buf, " %7d ", e.pid)
+
+	if ent.Caller.Defined {
+		buf.AppendString(filepath.Base(ent.Caller.File))
+		buf.AppendByte(':')
+		buf.AppendInt(int64(ent.Caller.Line))
+	} else {
+		buf.AppendString("???:1")
+	}
+
+	buf.AppendString("] ")
+	buf.AppendString(strconv.Quote(ent.Message))
+
+	if ent.LoggerName != "" {
+		appendKeyValue(buf, "logger", ent.LoggerName)
+	}
+
+	appendFields(buf, e.Fields)
+
+	// fields are encoded one by one to keep their order
+	for _, f := range fields {
+		m := zapcore.NewMapObjectEncoder()
+		f.AddTo(m)
+
+		appendFields(buf, m.Fields)
+	}
+
+	if ent.Stack != "" {
+		buf.AppendByte('\n')
+		buf.AppendString(ent.Stack)
+	}
+
+	buf.AppendByte('\n')
+
+	return buf, nil
+}
+
+func appendFields(buf *buffer.Buffer, fields map[string]any) {
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		appendKeyValue(buf, key, fields[key])
+	}
+}
+
+func appendKeyValue(buf *buffer.Buffer, key string, value any) {
+	buf.AppendByte(' ')
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	buf.AppendString(formatValue(value))
+}
+
+// formatValue quotes strings like klog and writes nested objects and arrays as JSON.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case time.Time:
+		return strconv.Quote(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return strconv.Quote(v.String())
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, complex64, complex128:
+		return fmt.Sprint(v)
+	case fmt.Stringer:
+		return strconv.Quote(v.String())
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+
+	return string(data)
+}
+
+func severity(lvl zapcore.Level) byte {
+	switch {
+	case lvl >= zapcore.FatalLevel:
+		return 'F'
+	case lvl >= zapcore.ErrorLevel:
+		return 'E'
+	case lvl == zapcore.WarnLevel:
+		return 'W'
+	default:
+		return 'I'
+	}
+}
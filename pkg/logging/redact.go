@@ -0,0 +1,256 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces secrets in logs and errors.
+const Redacted = "<redacted>"
+
+var (
+	// secretPatterns match secrets by their format.
+	secretPatterns = []*regexp.Regexp{
+		// PEM blocks, including truncated ones
+		regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]+-----(?:.*?-----END [A-Z0-9 ]+-----|.*)`),
+		// JWTs
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		// Vault service, batch and recovery tokens
+		regexp.MustCompile(`\bhv[sbr]\.[A-Za-z0-9_-]{20,}`),
+		// legacy Vault tokens
+		regexp.MustCompile(`\b[sbr]\.[A-Za-z0-9]{24,}`),
+	}
+
+	// secretFieldPattern matches the values of known secret fields, e.g. secret_id=abc, -token=abc or map[password:abc].
+	secretFieldPattern = regexp.MustCompile(`(?i)\b(client_token|token|secret_id|secret-id|password|jwt)([=:])("[^"]*"|[^\s,&"\]}]+)`)
+
+	// secretJSONFieldPattern matches the values of known secret fields in JSON, e.g. {"secret_id":"abc"}.
+	secretJSONFieldPattern = regexp.MustCompile(`(?i)"(client_token|token|secret_id|password|jwt)"\s*:\s*"[^"]*"`)
+
+	// secretKeys are the keys of fields, whose values are always redacted.
+	secretKeys = map[string]bool{
+		"token":             true,
+		"client_token":      true,
+		"secret_id":         true,
+		"approle_secret_id": true,
+		"password":          true,
+		"userpass_password": true,
+		"jwt":               true,
+	}
+)
+
+// Redact masks Vault tokens, JWTs, PEM blocks and the values of known secret fields in s.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.ReplaceAllString(s, Redacted)
+	}
+
+	s = secretFieldPattern.ReplaceAllString(s, "${1}${2}"+Redacted)
+
+	return secretJSONFieldPattern.ReplaceAllString(s, `"${1}":"`+Redacted+`"`)
+}
+
+func isSecretKey(key string) bool {
+	return secretKeys[strings.ReplaceAll(strings.ToLower(key), "-", "_")]
+}
+
+// redactCore redacts the message and the fields of entries before writing them.
+type redactCore struct {
+	zapcore.Core
+}
+
+func newRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = Redact(ent.Message)
+
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+
+	for i, f := range fields {
+		redacted[i] = redactField(f)
+	}
+
+	return redacted
+}
+
+func redactField(f zapcore.Field) zapcore.Field {
+	if isSecretKey(f.Key) && f.Type != zapcore.SkipType {
+		return zap.String(f.Key, Redacted)
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = Redact(f.String)
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return zap.ByteString(f.Key, []byte(Redact(string(b))))
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return zap.NamedError(f.Key, &redactedError{err: err})
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok {
+			return zap.Stringer(f.Key, redactedStringer{s})
+		}
+	case zapcore.ReflectType:
+		return zap.Reflect(f.Key, redactValue(f.Interface))
+	case zapcore.ArrayMarshalerType:
+		if arr, ok := f.Interface.(zapcore.ArrayMarshaler); ok {
+			return zap.Array(f.Key, redactedArray{arr})
+		}
+	case zapcore.ObjectMarshalerType:
+		if obj, ok := f.Interface.(zapcore.ObjectMarshaler); ok {
+			return zap.Object(f.Key, redactedObject{obj})
+		}
+	case zapcore.InlineMarshalerType:
+		if obj, ok := f.Interface.(zapcore.ObjectMarshaler); ok {
+			return zap.Inline(redactedObject{obj})
+		}
+	}
+
+	return f
+}
+
+// redactValue returns the JSON encoding of v with secrets redacted, e.g. of a map or struct logged with zap.Any.
+// If the redacted encoding is no longer valid JSON, e.g. because of a truncated PEM block, it is returned as string.
+func redactValue(v any) any {
+	buf := &bytes.Buffer{}
+
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return Redact(fmt.Sprintf("%+v", v))
+	}
+
+	redacted := Redact(string(bytes.TrimSpace(buf.Bytes())))
+	if !json.Valid([]byte(redacted)) {
+		return redacted
+	}
+
+	return json.RawMessage(redacted)
+}
+
+// redactedError redacts the message of the wrapped error.
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+type redactedStringer struct {
+	fmt.Stringer
+}
+
+func (s redactedStringer) String() string {
+	return Redact(s.Stringer.String())
+}
+
+// redactedObject redacts the values an object marshaler adds to the encoder.
+type redactedObject struct {
+	zapcore.ObjectMarshaler
+}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.ObjectMarshaler.MarshalLogObject(redactingObjectEncoder{enc})
+}
+
+// redactedArray redacts the values an array marshaler appends to the encoder.
+type redactedArray struct {
+	zapcore.ArrayMarshaler
+}
+
+func (a redactedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.ArrayMarshaler.MarshalLogArray(redactingArrayEncoder{enc})
+}
+
+type redactingObjectEncoder struct {
+	zapcore.ObjectEncoder
+}
+
+func (e redactingObjectEncoder) AddString(key, value string) {
+	if isSecretKey(key) {
+		e.ObjectEncoder.AddString(key, Redacted)
+
+		return
+	}
+
+	e.ObjectEncoder.AddString(key, Redact(value))
+}
+
+func (e redactingObjectEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e redactingObjectEncoder) AddReflected(key string, value any) error {
+	if isSecretKey(key) {
+		e.ObjectEncoder.AddString(key, Redacted)
+
+		return nil
+	}
+
+	return e.ObjectEncoder.AddReflected(key, redactValue(value))
+}
+
+func (e redactingObjectEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return e.ObjectEncoder.AddArray(key, redactedArray{arr})
+}
+
+func (e redactingObjectEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return e.ObjectEncoder.AddObject(key, redactedObject{obj})
+}
+
+type redactingArrayEncoder struct {
+	zapcore.ArrayEncoder
+}
+
+func (e redactingArrayEncoder) AppendString(value string) {
+	e.ArrayEncoder.AppendString(Redact(value))
+}
+
+func (e redactingArrayEncoder) AppendByteString(value []byte) {
+	e.AppendString(string(value))
+}
+
+func (e redactingArrayEncoder) AppendReflected(value any) error {
+	return e.ArrayEncoder.AppendReflected(redactValue(value))
+}
+
+func (e redactingArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactedArray{arr})
+}
+
+func (e redactingArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactedObject{obj})
+}
@@ -1,35 +1,114 @@
 package logging
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Supported log encodings.
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+	EncodingKlog    = "klog"
 )
 
+// Config configures the logger.
+type Config struct {
+	// Level is the level of all loggers without a component level, it can be changed at runtime.
+	Level zap.AtomicLevel
+
+	// ComponentLevels overrides the level of the loggers of components.
+	ComponentLevels map[string]zapcore.Level
+
+	// Encoding is one of json, console or klog.
+	Encoding string
+
+	// Sampling limits repeated log messages to SamplingInitial per second, logging every SamplingThereafter message afterwards.
+	Sampling           bool
+	SamplingInitial    int
+	SamplingThereafter int
+
+	// File is the path of the log file, empty logs to stdout.
+	// The file is rotated once it reaches FileMaxSizeMB, keeping FileMaxBackups rotated files.
+	File           string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+}
+
 // NewStandardLogger creates a new zap.Logger based on common configuration
 // https://github.com/kubernetes-sigs/aws-encryption-provider/blob/master/pkg/logging/zap.go
+// The level can be changed at runtime.
 // nolint: mnd
-func NewStandardLogger(logLevel zapcore.Level) (*zap.Logger, error) {
-	return zap.Config{
-		Level:       zap.NewAtomicLevelAt(logLevel),
-		Development: false,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding: "json",
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "timestamp",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			MessageKey:     "message",
-			StacktraceKey:  "stacktrace",
-			EncodeLevel:    zapcore.LowercaseLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stderr"},
-	}.Build()
+func NewStandardLogger(level zap.AtomicLevel) (*zap.Logger, error) {
+	return New(Config{
+		Level:              level,
+		Encoding:           EncodingJSON,
+		Sampling:           true,
+		SamplingInitial:    100,
+		SamplingThereafter: 100,
+	})
+}
+
+// New creates a new zap.Logger, that redacts secrets in messages, fields and errors (see Redact).
+func New(cfg Config) (*zap.Logger, error) {
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "timestamp",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "message",
+		StacktraceKey:  "stacktrace",
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+
+	var encoder zapcore.Encoder
+
+	switch cfg.Encoding {
+	case EncodingJSON, "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case EncodingConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	case EncodingKlog:
+		encoder = newKlogEncoder()
+	default:
+		return nil, fmt.Errorf("invalid log encoding: %s. Supported: json, console, klog", cfg.Encoding)
+	}
+
+	output := zapcore.Lock(os.Stdout)
+
+	if cfg.File != "" {
+		output = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.FileMaxSizeMB,
+			MaxBackups: cfg.FileMaxBackups,
+		})
+	}
+
+	levels := newComponentLevels(cfg.Level, cfg.ComponentLevels)
+
+	// the redaction wraps the io core, so that the sampler and component levels still decide which entries are written
+	core := newRedactCore(zapcore.NewCore(encoder, output, zap.LevelEnablerFunc(levels.minEnabled)))
+
+	if cfg.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.SamplingInitial, cfg.SamplingThereafter)
+	}
+
+	if len(cfg.ComponentLevels) > 0 {
+		core = &componentCore{Core: core, levels: levels}
+	}
+
+	return zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	), nil
 }
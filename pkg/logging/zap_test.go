@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newFileLogger(t *testing.T, cfg Config) (*zap.Logger, func() []string) {
+	t.Helper()
+
+	cfg.File = filepath.Join(t.TempDir(), "kms.log")
+
+	l, err := New(cfg)
+	require.NoError(t, err)
+
+	return l, func() []string {
+		require.NoError(t, l.Sync())
+
+		data, err := os.ReadFile(cfg.File)
+		require.NoError(t, err)
+
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestNewInvalidEncoding(t *testing.T) {
+	_, err := New(Config{Level: zap.NewAtomicLevel(), Encoding: "logfmt"})
+	require.Error(t, err)
+}
+
+func TestKlogEncoding(t *testing.T) {
+	l, lines := newFileLogger(t, Config{Level: zap.NewAtomicLevel(), Encoding: EncodingKlog})
+
+	l.Named("vault").With(zap.String("mount", "transit")).Warn("token expires soon", zap.Int("ttl", 30), zap.String("key", "kms"))
+
+	got := lines()
+	require.Len(t, got, 1)
+	require.Regexp(t,
+		regexp.MustCompile(`^W\d{4} \d{2}:\d{2}:\d{2}\.\d{6} +\d+ zap_test\.go:\d+\] "token expires soon" logger="vault" mount="transit" ttl=30 key="kms"$`),
+		got[0])
+}
+
+func TestComponentLevels(t *testing.T) {
+	l, lines := newFileLogger(t, Config{
+		Level:           zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		ComponentLevels: map[string]zapcore.Level{ComponentVault: zapcore.DebugLevel, ComponentHTTP: zapcore.WarnLevel},
+		Encoding:        EncodingJSON,
+	})
+
+	l.Debug("default debug")
+	l.Info("default info")
+	l.Named(ComponentVault).Debug("vault debug")
+	l.Named(ComponentVault).Named("lease").Debug("vault lease debug")
+	l.Named(ComponentHTTP).Info("http info")
+	l.Named(ComponentHTTP).Warn("http warn")
+
+	got := strings.Join(lines(), "\n")
+
+	for _, msg := range []string{"default info", "vault debug", "vault lease debug", "http warn"} {
+		require.Contains(t, got, `"message":"`+msg+`"`)
+	}
+
+	for _, msg := range []string{"default debug", "http info"} {
+		require.NotContains(t, got, `"message":"`+msg+`"`)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	for _, tc := range []struct {
+		sampling bool
+		exp      int
+	}{
+		{sampling: true, exp: 2},
+		{sampling: false, exp: 10},
+	} {
+		l, lines := newFileLogger(t, Config{
+			Level:              zap.NewAtomicLevel(),
+			Encoding:           EncodingConsole,
+			Sampling:           tc.sampling,
+			SamplingInitial:    2,
+			SamplingThereafter: 100,
+		})
+
+		for range 10 {
+			l.Info("repeated")
+		}
+
+		require.Len(t, lines(), tc.exp, tc.sampling)
+	}
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels(" vault=debug, http=warn ,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]zapcore.Level{ComponentVault: zapcore.DebugLevel, ComponentHTTP: zapcore.WarnLevel}, levels)
+
+	levels, err = ParseComponentLevels("")
+	require.NoError(t, err)
+	require.Empty(t, levels)
+
+	for _, s := range []string{"vault", "transit=debug", "vault=verbose"} {
+		_, err := ParseComponentLevels(s)
+		require.Error(t, err, s)
+	}
+}
@@ -1,12 +1,28 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 const MetricsPrefix = "vault_kubernetes_kms"
 
+// Values of the outcome label.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// DefaultVaultRequestsBuckets are the buckets of the Vault request duration histogram in seconds.
+// Vault usually responds within a few milliseconds, so the buckets are finer than the prometheus defaults.
+var DefaultVaultRequestsBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.0075, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
 var metricsPrefix = func(s string) string {
 	return MetricsPrefix + "_" + s
 }
@@ -26,46 +42,246 @@ func RegisterPrometheusMetrics() *prometheus.Registry {
 		VaultTokenRenewalTotal,
 		VaultTokenExpirySeconds,
 		VaultRequestsDurationSeconds,
+		VaultTransitBatchSize,
+		VaultServerState,
+		VaultClientRateLimit,
+		VaultRateLimitedRequestsTotal,
+		GRPCRequestsTotal,
+		GRPCRequestDurationSeconds,
+		GRPCPanicsTotal,
+		GRPCInFlightRequests,
+		GRPCLimiterInFlightRequests,
+		GRPCQueuedRequests,
+		GRPCRejectedRequestsTotal,
+		SocketRejectedConnectionsTotal,
+		AuditWriteErrorsTotal,
+		TransitKeyVersion,
+		VaultAuthTotal,
+		BuildInfo,
 	)
 
 	return promReg
 }
 
+// SetBuildInfo sets the build_info metric of the running binary.
+func SetBuildInfo(version, commit string) {
+	BuildInfo.Reset()
+	BuildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
+
+// SetVaultRequestsBuckets replaces the Vault request duration histogram with one using the given buckets.
+// It must be called before RegisterPrometheusMetrics.
+func SetVaultRequestsBuckets(buckets []float64) {
+	VaultRequestsDurationSeconds = newVaultRequestsDurationSeconds(buckets)
+}
+
+// ParseBuckets parses a comma separated list of histogram buckets in seconds, an empty list returns no buckets.
+func ParseBuckets(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var buckets []float64
+
+	for _, b := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %w", b, err)
+		}
+
+		if v <= 0 {
+			return nil, fmt.Errorf("invalid bucket %q: must be positive", b)
+		}
+
+		if len(buckets) > 0 && v <= buckets[len(buckets)-1] {
+			return nil, errors.New("buckets must be in increasing order")
+		}
+
+		buckets = append(buckets, v)
+	}
+
+	return buckets, nil
+}
+
+func newVaultRequestsDurationSeconds(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    metricsPrefix("vault_requests_duration_seconds"),
+			Help:    "duration of vault requests in seconds by templated path and operation",
+			Buckets: buckets,
+		},
+		[]string{"provider", "method", "path", "operation", "status"},
+	)
+}
+
+// Outcome returns the value of the outcome label for an error.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+}
+
 var (
-	VaultRequestsDurationSeconds = prometheus.NewHistogramVec(
+	VaultRequestsDurationSeconds = newVaultRequestsDurationSeconds(DefaultVaultRequestsBuckets)
+
+	VaultTransitBatchSize = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name: metricsPrefix("vault_requests_duration_seconds"),
-			Help: "duration of vault requests in seconds",
+			Name:    metricsPrefix("vault_transit_batch_size"),
+			Help:    "number of items sent in a single vault transit batch request",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 9),
+		},
+		[]string{"provider", "operation"},
+	)
+
+	VaultServerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("vault_server_state"),
+			Help: "state of the vault server as reported by sys/health (1 if the state applies, 0 otherwise)",
+		},
+		[]string{"state"},
+	)
+
+	VaultClientRateLimit = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("vault_client_rate_limit"),
+			Help: "current client side limit of vault requests per second (0 if unlimited)",
+		},
+	)
+
+	VaultRateLimitedRequestsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("vault_rate_limited_requests_total"),
+			Help: "total number of vault requests rejected with 429 too many requests",
+		},
+	)
+
+	GRPCRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("grpc_requests_total"),
+			Help: "total number of grpc requests by method, api version, operation, outcome and status code",
+		},
+		[]string{"provider", "method", "api_version", "operation", "outcome", "code"},
+	)
+
+	GRPCRequestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: metricsPrefix("grpc_request_duration_seconds"),
+			Help: "duration of grpc requests in seconds by method, api version, operation, outcome and status code",
+		},
+		[]string{"provider", "method", "api_version", "operation", "outcome", "code"},
+	)
+
+	GRPCPanicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("grpc_panics_total"),
+			Help: "total number of recovered panics in grpc handlers",
+		},
+		[]string{"provider", "method"},
+	)
+
+	GRPCInFlightRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("grpc_in_flight_requests"),
+			Help: "number of grpc requests currently being processed by method, api version and operation",
+		},
+		[]string{"provider", "method", "api_version", "operation"},
+	)
+
+	GRPCLimiterInFlightRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("grpc_limiter_in_flight_requests"),
+			Help: "number of grpc requests currently holding a slot of the limiter by limiter class",
+		},
+		[]string{"provider", "class"},
+	)
+
+	GRPCQueuedRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("grpc_queued_requests"),
+			Help: "number of grpc requests currently waiting for a free slot by limiter class",
+		},
+		[]string{"provider", "class"},
+	)
+
+	GRPCRejectedRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("grpc_rejected_requests_total"),
+			Help: "total number of grpc requests rejected because the limiter was saturated",
+		},
+		[]string{"provider", "class"},
+	)
+
+	SocketRejectedConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("socket_rejected_connections_total"),
+			Help: "total number of socket connections rejected by the peer credential allow list",
+		},
+		[]string{"provider", "reason"},
+	)
+
+	AuditWriteErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("audit_write_errors_total"),
+			Help: "total number of audit entries, that could not be written to the audit sink",
+		},
+	)
+
+	TransitKeyVersion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("transit_key_version"),
+			Help: "latest version of the transit key",
+		},
+		[]string{"provider", "transit_mount", "transit_key"},
+	)
+
+	VaultAuthTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: metricsPrefix("vault_auth_total"),
+			Help: "total number of vault logins by auth method and outcome",
+		},
+		[]string{"method", "outcome"},
+	)
+
+	BuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: metricsPrefix("build_info"),
+			Help: "build information of the plugin, the value is always 1",
 		},
-		[]string{"method", "path", "status"},
+		[]string{"version", "commit", "go_version"},
 	)
 
-	EncryptionOperationDurationSeconds = prometheus.NewHistogram(
+	EncryptionOperationDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: metricsPrefix("encryption_operation_duration_seconds"),
-			Help: "duration of encryption operations",
+			Help: "deprecated: use grpc_request_duration_seconds, duration of encryption operations",
 		},
+		[]string{"provider"},
 	)
 
-	DecryptionOperationDurationSeconds = prometheus.NewHistogram(
+	DecryptionOperationDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: metricsPrefix("decryption_operation_duration_seconds"),
-			Help: "duration of decryption operations",
+			Help: "deprecated: use grpc_request_duration_seconds, duration of decryption operations",
 		},
+		[]string{"provider"},
 	)
 
-	EncryptionErrorsTotal = prometheus.NewCounter(
+	EncryptionErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: metricsPrefix("encryption_operation_errors_total"),
-			Help: "total number of errors during encryption operations",
+			Help: "deprecated: use grpc_requests_total, total number of errors during encryption operations",
 		},
+		[]string{"provider", "code"},
 	)
 
-	DecryptionErrorsTotal = prometheus.NewCounter(
+	DecryptionErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: metricsPrefix("decryption_operation_errors_total"),
-			Help: "total number of errors during decryption operations",
+			Help: "deprecated: use grpc_requests_total, total number of errors during decryption operations",
 		},
+		[]string{"provider", "code"},
 	)
 
 	VaultTokenRenewalTotal = prometheus.NewCounter(
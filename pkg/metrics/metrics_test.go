@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets(" 0.001, 0.01,1 ")
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.001, 0.01, 1}, buckets)
+
+	buckets, err = ParseBuckets("")
+	require.NoError(t, err)
+	require.Empty(t, buckets)
+
+	for _, invalid := range []string{"0.01,0.001", "0.01,0.01", "0,1", "-1", "1ms"} {
+		_, err = ParseBuckets(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import "context"
+
+// DefaultProvider is the provider label of metrics recorded outside of a KMS provider.
+const DefaultProvider = "default"
+
+type providerKey struct{}
+
+// WithProvider returns a context carrying the name of the KMS provider, that is used as metrics label.
+func WithProvider(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, providerKey{}, provider)
+}
+
+// Provider returns the name of the KMS provider carried by the context or DefaultProvider.
+func Provider(ctx context.Context) string {
+	if provider, ok := ctx.Value(providerKey{}).(string); ok && provider != "" {
+		return provider
+	}
+
+	return DefaultProvider
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"github.com/hashicorp/vault/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// messages returned by Vaults transit engine, that don't have a dedicated HTTP status code.
+var (
+	invalidArgumentMessages = []string{
+		"invalid ciphertext",
+		"message authentication failed",
+		"invalid version",
+		"illegal base64 data",
+	}
+
+	notFoundMessages = []string{
+		"encryption key not found",
+		"key not found",
+	}
+)
+
+// statusError converts errors into gRPC status errors, so that the kube-apiserver
+// receives a precise status code instead of codes.Unknown. Secrets are redacted from the message.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if s, ok := status.FromError(err); ok {
+		if msg := logging.Redact(s.Message()); msg != s.Message() {
+			return status.Error(s.Code(), msg)
+		}
+
+		return err
+	}
+
+	msg := logging.Redact(err.Error())
+
+	code := classify(err)
+	if code == codes.Unknown && msg == err.Error() {
+		return err
+	}
+
+	return status.Error(code, msg)
+}
+
+// classify maps an error returned by the plugin to a gRPC status code.
+// nolint: cyclop
+func classify(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, vault.ErrVaultSealed),
+		errors.Is(err, vault.ErrVaultNotInitialized),
+		errors.Is(err, vault.ErrVaultDRSecondary):
+		return codes.Unavailable
+	case errors.Is(err, vault.ErrTransitKeyNotFound):
+		return codes.NotFound
+	}
+
+	var respErr *api.ResponseError
+	if errors.As(err, &respErr) {
+		return classifyResponseError(respErr)
+	}
+
+	var itemErr *vault.BatchItemError
+	if errors.As(err, &itemErr) {
+		return classifyResponseError(&api.ResponseError{StatusCode: itemErr.StatusCode, Errors: []string{itemErr.Message}})
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return codes.DeadlineExceeded
+		}
+
+		return codes.Unavailable
+	}
+
+	return classifyMessage(err.Error(), codes.Unknown)
+}
+
+// classifyResponseError maps the HTTP status code of a Vault response to a gRPC status code.
+func classifyResponseError(respErr *api.ResponseError) codes.Code {
+	switch respErr.StatusCode {
+	case http.StatusBadRequest:
+		return classifyMessage(strings.Join(respErr.Errors, " "), codes.InvalidArgument)
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusPreconditionFailed:
+		// returned by performance standbys that have not yet caught up with the active node
+		return codes.Unavailable
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+
+	return codes.Unknown
+}
+
+func classifyMessage(msg string, fallback codes.Code) codes.Code {
+	msg = strings.ToLower(msg)
+
+	for _, m := range notFoundMessages {
+		if strings.Contains(msg, m) {
+			return codes.NotFound
+		}
+	}
+
+	for _, m := range invalidArgumentMessages {
+		if strings.Contains(msg, m) {
+			return codes.InvalidArgument
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v2 "k8s.io/kms/apis/v2"
+)
+
+func responseError(code int, msg string) error {
+	return fmt.Errorf("error writing transit: %w", &api.ResponseError{
+		HTTPMethod: http.MethodPut,
+		URL:        "https://vault/v1/transit/decrypt/kms",
+		StatusCode: code,
+		Errors:     []string{msg},
+	})
+}
+
+//nolint:funlen
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		exp  codes.Code
+	}{
+		{
+			name: "nil",
+			exp:  codes.OK,
+		},
+		{
+			name: "status error is kept",
+			err:  status.Error(codes.Aborted, "aborted"),
+			exp:  codes.Aborted,
+		},
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("request failed: %w", context.DeadlineExceeded),
+			exp:  codes.DeadlineExceeded,
+		},
+		{
+			name: "canceled",
+			err:  context.Canceled,
+			exp:  codes.Canceled,
+		},
+		{
+			name: "vault sealed",
+			err:  vault.ErrVaultSealed,
+			exp:  codes.Unavailable,
+		},
+		{
+			name: "transit key not found",
+			err:  fmt.Errorf("could not read transit key: %w", vault.ErrTransitKeyNotFound),
+			exp:  codes.NotFound,
+		},
+		{
+			name: "permission denied",
+			err:  responseError(http.StatusForbidden, "permission denied"),
+			exp:  codes.PermissionDenied,
+		},
+		{
+			name: "unauthenticated",
+			err:  responseError(http.StatusUnauthorized, "missing client token"),
+			exp:  codes.Unauthenticated,
+		},
+		{
+			name: "invalid ciphertext",
+			err:  responseError(http.StatusBadRequest, "invalid ciphertext: no prefix"),
+			exp:  codes.InvalidArgument,
+		},
+		{
+			name: "key not found",
+			err:  responseError(http.StatusBadRequest, "encryption key not found"),
+			exp:  codes.NotFound,
+		},
+		{
+			name: "other bad request",
+			err:  responseError(http.StatusBadRequest, "missing plaintext"),
+			exp:  codes.InvalidArgument,
+		},
+		{
+			name: "rate limited",
+			err:  responseError(http.StatusTooManyRequests, "request path \"transit/encrypt/kms\": rate limit quota exceeded"),
+			exp:  codes.ResourceExhausted,
+		},
+		{
+			name: "internal error",
+			err:  responseError(http.StatusInternalServerError, "internal error"),
+			exp:  codes.Internal,
+		},
+		{
+			name: "vault unavailable",
+			err:  responseError(http.StatusServiceUnavailable, "Vault is sealed"),
+			exp:  codes.Unavailable,
+		},
+		{
+			name: "connection refused",
+			err: &url.Error{Op: "Put", URL: "https://vault", Err: &net.OpError{
+				Op: "dial", Net: "tcp", Err: errors.New("connection refused"),
+			}},
+			exp: codes.Unavailable,
+		},
+		{
+			name: "invalid ciphertext in batch item",
+			err:  &vault.BatchItemError{StatusCode: http.StatusBadRequest, Message: "cipher: message authentication failed"},
+			exp:  codes.InvalidArgument,
+		},
+		{
+			name: "other batch item error",
+			err:  &vault.BatchItemError{StatusCode: http.StatusBadRequest, Message: "missing ciphertext"},
+			exp:  codes.InvalidArgument,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("something went wrong"),
+			exp:  codes.Unknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.exp, classify(tc.err))
+		})
+	}
+}
+
+func TestStatusErrorKeepsUnknownErrors(t *testing.T) {
+	err := errors.New("vault unavailable")
+
+	require.Equal(t, err, statusError(err))
+	require.NoError(t, statusError(nil))
+}
+
+func TestStatusErrorRedactsSecrets(t *testing.T) {
+	const token = "hvs.CAESIJlWh1eLbjOsZ3Ao6Bq0hZJ7pJzKp8GQh3Jl9cAqT8aGh4KHGh2cy5"
+
+	testCases := []struct {
+		name string
+		err  error
+		exp  codes.Code
+	}{
+		{
+			name: "response error",
+			err:  responseError(http.StatusForbidden, "permission denied for token "+token),
+			exp:  codes.PermissionDenied,
+		},
+		{
+			name: "status error",
+			err:  status.Error(codes.Aborted, "aborted with token "+token),
+			exp:  codes.Aborted,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("something went wrong with token " + token),
+			exp:  codes.Unknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := status.Convert(statusError(tc.err))
+
+			require.Equal(t, tc.exp, s.Code())
+			require.NotContains(t, s.Message(), token)
+			require.Contains(t, s.Message(), logging.Redacted)
+		})
+	}
+}
+
+func TestKMSv2DecryptRecordsErrorCode(t *testing.T) {
+	resetPluginMetrics()
+
+	kms := NewPluginV2(&fakePlugin{
+		decryptErr: responseError(http.StatusBadRequest, "invalid ciphertext: no prefix"),
+	})
+
+	_, err := kms.Decrypt(context.Background(), &v2.DecryptRequest{Ciphertext: []byte("cipher")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.InDelta(t, 1, counterValue(t, metrics.DecryptionErrorsTotal, codes.InvalidArgument.String()), 0)
+	require.Zero(t, counterValue(t, metrics.DecryptionErrorsTotal, codes.Unknown.String()))
+}
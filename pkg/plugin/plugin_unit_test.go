@@ -6,8 +6,12 @@ import (
 	"testing"
 
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/vault"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	v1beta1 "k8s.io/kms/apis/v1beta1"
 	v2 "k8s.io/kms/apis/v2"
 )
@@ -45,29 +49,33 @@ func (f *fakePlugin) GetKeyVersion(ctx context.Context) (string, error) {
 }
 
 func resetPluginMetrics() {
-	metrics.EncryptionErrorsTotal = prometheus.NewCounter(
+	metrics.EncryptionErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "vault_kubernetes_kms_encryption_operation_errors_total",
 			Help: "total number of errors during encryption operations",
 		},
+		[]string{"provider", "code"},
 	)
-	metrics.DecryptionErrorsTotal = prometheus.NewCounter(
+	metrics.DecryptionErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "vault_kubernetes_kms_decryption_operation_errors_total",
 			Help: "total number of errors during decryption operations",
 		},
+		[]string{"provider", "code"},
 	)
-	metrics.EncryptionOperationDurationSeconds = prometheus.NewHistogram(
+	metrics.EncryptionOperationDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "vault_kubernetes_kms_encryption_operation_duration_seconds",
 			Help: "duration of encryption operations",
 		},
+		[]string{"provider"},
 	)
-	metrics.DecryptionOperationDurationSeconds = prometheus.NewHistogram(
+	metrics.DecryptionOperationDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "vault_kubernetes_kms_decryption_operation_duration_seconds",
 			Help: "duration of decryption operations",
 		},
+		[]string{"provider"},
 	)
 }
 
@@ -79,14 +87,20 @@ func histogramSampleCount(t *testing.T, collector prometheus.Collector) uint64 {
 
 	families, err := registry.Gather()
 	require.NoError(t, err)
-	require.Len(t, families, 1)
-	require.Len(t, families[0].GetMetric(), 1)
-	metric := families[0].GetMetric()[0]
 
-	return metric.GetHistogram().GetSampleCount()
+	var count uint64
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			count += metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	return count
 }
 
-func counterValue(t *testing.T, collector prometheus.Collector) float64 {
+// counterValue returns the sum of all counters of the collector, optionally filtered by the code label.
+func counterValue(t *testing.T, collector prometheus.Collector, code ...string) float64 {
 	t.Helper()
 
 	registry := prometheus.NewRegistry()
@@ -94,11 +108,30 @@ func counterValue(t *testing.T, collector prometheus.Collector) float64 {
 
 	families, err := registry.Gather()
 	require.NoError(t, err)
-	require.Len(t, families, 1)
-	require.Len(t, families[0].GetMetric(), 1)
-	metric := families[0].GetMetric()[0]
 
-	return metric.GetCounter().GetValue()
+	var total float64
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if len(code) > 0 && !hasLabel(metric.GetLabel(), "code", code[0]) {
+				continue
+			}
+
+			total += metric.GetCounter().GetValue()
+		}
+	}
+
+	return total
+}
+
+func hasLabel(labels []*dto.LabelPair, name, value string) bool {
+	for _, l := range labels {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 func TestKMSv1UsesPluginInterface(t *testing.T) {
@@ -204,3 +237,29 @@ func TestKMSv2DecryptRecordsMetricsOnlyForNormalTraffic(t *testing.T) {
 	require.Equal(t, uint64(1), histogramSampleCount(t, metrics.DecryptionOperationDurationSeconds))
 	require.Zero(t, counterValue(t, metrics.DecryptionErrorsTotal))
 }
+
+func TestKMSReturnsUnavailableWhenVaultIsSealed(t *testing.T) {
+	resetPluginMetrics()
+
+	fake := &fakePlugin{
+		encryptErr:    vault.ErrVaultSealed,
+		decryptErr:    vault.ErrVaultSealed,
+		keyVersionErr: vault.ErrVaultSealed,
+	}
+
+	_, err := NewPluginV2(fake).Encrypt(context.Background(), &v2.EncryptRequest{Plaintext: []byte("plain")})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "vault sealed")
+
+	_, err = NewPluginV2(fake).Decrypt(context.Background(), &v2.DecryptRequest{Ciphertext: []byte("cipher")})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.InDelta(t, 1, counterValue(t, metrics.EncryptionErrorsTotal, codes.Unavailable.String()), 0)
+	require.InDelta(t, 1, counterValue(t, metrics.DecryptionErrorsTotal, codes.Unavailable.String()), 0)
+
+	_, err = NewPluginV2(fake).Status(context.Background(), &v2.StatusRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	//nolint:staticcheck // KMS v1 coverage is intentional here.
+	_, err = NewPluginV1(fake).Encrypt(context.Background(), &v1beta1.EncryptRequest{Plain: []byte("plain")})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
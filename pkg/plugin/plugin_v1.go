@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
 	"github.com/prometheus/client_golang/prometheus"
-	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 	pb "k8s.io/kms/apis/v1beta1"
 )
 
@@ -49,7 +50,7 @@ func (v1 *KMSv1) Health(ctx context.Context) error {
 	}
 
 	if health != string(dec.GetPlain()) {
-		zap.L().Info("v1 health status failed")
+		logging.L(logging.ComponentPlugin).Info("v1 health status failed")
 
 		return errors.New("v1 health check failed")
 	}
@@ -75,25 +76,33 @@ func (v1 *KMSv1) Register(s *grpc.Server) {
 	pb.RegisterKeyManagementServiceServer(s, v1)
 }
 
+// ServiceName returns the fully qualified name of the KMS v1 gRPC service.
+// nolint: staticcheck
+func (v1 *KMSv1) ServiceName() string {
+	return pb.KeyManagementService_ServiceDesc.ServiceName
+}
+
 // nolint: staticcheck
 func (v1 *KMSv1) encrypt(ctx context.Context, plain []byte, recordMetrics bool) (*pb.EncryptResponse, error) {
 	var timer *prometheus.Timer
 	if recordMetrics {
-		timer = prometheus.NewTimer(metrics.EncryptionOperationDurationSeconds)
+		timer = prometheus.NewTimer(metrics.EncryptionOperationDurationSeconds.WithLabelValues(metrics.Provider(ctx)))
 		defer timer.ObserveDuration()
 	}
 
 	resp, _, err := v1.plugin.Encrypt(ctx, plain)
 	if err != nil {
+		err = statusError(err)
+
 		if recordMetrics {
-			metrics.EncryptionErrorsTotal.Inc()
+			metrics.EncryptionErrorsTotal.WithLabelValues(metrics.Provider(ctx), status.Code(err).String()).Inc()
 		}
 
 		return nil, err
 	}
 
 	if recordMetrics {
-		zap.L().Info("v1 encryption request")
+		logging.L(logging.ComponentPlugin).Info("v1 encryption request")
 	}
 
 	return &pb.EncryptResponse{
@@ -105,21 +114,23 @@ func (v1 *KMSv1) encrypt(ctx context.Context, plain []byte, recordMetrics bool)
 func (v1 *KMSv1) decrypt(ctx context.Context, cipher []byte, recordMetrics bool) (*pb.DecryptResponse, error) {
 	var timer *prometheus.Timer
 	if recordMetrics {
-		timer = prometheus.NewTimer(metrics.DecryptionOperationDurationSeconds)
+		timer = prometheus.NewTimer(metrics.DecryptionOperationDurationSeconds.WithLabelValues(metrics.Provider(ctx)))
 		defer timer.ObserveDuration()
 	}
 
 	resp, err := v1.plugin.Decrypt(ctx, cipher)
 	if err != nil {
+		err = statusError(err)
+
 		if recordMetrics {
-			metrics.DecryptionErrorsTotal.Inc()
+			metrics.DecryptionErrorsTotal.WithLabelValues(metrics.Provider(ctx), status.Code(err).String()).Inc()
 		}
 
 		return nil, err
 	}
 
 	if recordMetrics {
-		zap.L().Info("v1 decryption request")
+		logging.L(logging.ComponentPlugin).Info("v1 decryption request")
 	}
 
 	return &pb.DecryptResponse{
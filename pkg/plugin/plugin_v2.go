@@ -6,10 +6,12 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 	pb "k8s.io/kms/apis/v2"
 )
 
@@ -38,7 +40,7 @@ func (v2 *KMSv2) Status(ctx context.Context, _ *pb.StatusRequest) (*pb.StatusRes
 
 	kv, err := v2.plugin.GetKeyVersion(ctx)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	//nolint: contextcheck
@@ -46,10 +48,10 @@ func (v2 *KMSv2) Status(ctx context.Context, _ *pb.StatusRequest) (*pb.StatusRes
 	if err != nil {
 		health = "err"
 
-		zap.L().Info("v2 health check failed", zap.Error(err))
+		logging.L(logging.ComponentPlugin).Info("v2 health check failed", zap.Error(err))
 	}
 
-	zap.L().Info("health status",
+	logging.L(logging.ComponentPlugin).Info("health status",
 		zap.String("key_id", kv),
 		zap.String("healthz", health),
 		zap.String("version", "v2"),
@@ -79,7 +81,7 @@ func (v2 *KMSv2) Health(ctx context.Context) error {
 	}
 
 	if health != string(dec.GetPlaintext()) {
-		zap.L().Info("v2 health status failed")
+		logging.L(logging.ComponentPlugin).Info("v2 health status failed")
 
 		return errors.New("v2 health check failed")
 	}
@@ -99,24 +101,31 @@ func (v2 *KMSv2) Register(s *grpc.Server) {
 	pb.RegisterKeyManagementServiceServer(s, v2)
 }
 
+// ServiceName returns the fully qualified name of the KMS v2 gRPC service.
+func (v2 *KMSv2) ServiceName() string {
+	return pb.KeyManagementService_ServiceDesc.ServiceName
+}
+
 func (v2 *KMSv2) encrypt(ctx context.Context, plain []byte, requestID string, recordMetrics bool) (*pb.EncryptResponse, error) {
 	var timer *prometheus.Timer
 	if recordMetrics {
-		timer = prometheus.NewTimer(metrics.EncryptionOperationDurationSeconds)
+		timer = prometheus.NewTimer(metrics.EncryptionOperationDurationSeconds.WithLabelValues(metrics.Provider(ctx)))
 		defer timer.ObserveDuration()
 	}
 
 	resp, id, err := v2.plugin.Encrypt(ctx, plain)
 	if err != nil {
+		err = statusError(err)
+
 		if recordMetrics {
-			metrics.EncryptionErrorsTotal.Inc()
+			metrics.EncryptionErrorsTotal.WithLabelValues(metrics.Provider(ctx), status.Code(err).String()).Inc()
 		}
 
 		return nil, err
 	}
 
 	if recordMetrics {
-		zap.L().Info("v2 encryption request", zap.String("request_id", requestID))
+		logging.L(logging.ComponentPlugin).Info("v2 encryption request", zap.String("request_id", requestID))
 	}
 
 	return &pb.EncryptResponse{
@@ -128,21 +137,23 @@ func (v2 *KMSv2) encrypt(ctx context.Context, plain []byte, requestID string, re
 func (v2 *KMSv2) decrypt(ctx context.Context, cipher []byte, requestID string, recordMetrics bool) (*pb.DecryptResponse, error) {
 	var timer *prometheus.Timer
 	if recordMetrics {
-		timer = prometheus.NewTimer(metrics.DecryptionOperationDurationSeconds)
+		timer = prometheus.NewTimer(metrics.DecryptionOperationDurationSeconds.WithLabelValues(metrics.Provider(ctx)))
 		defer timer.ObserveDuration()
 	}
 
 	resp, err := v2.plugin.Decrypt(ctx, cipher)
 	if err != nil {
+		err = statusError(err)
+
 		if recordMetrics {
-			metrics.DecryptionErrorsTotal.Inc()
+			metrics.DecryptionErrorsTotal.WithLabelValues(metrics.Provider(ctx), status.Code(err).String()).Inc()
 		}
 
 		return nil, err
 	}
 
 	if recordMetrics {
-		zap.L().Info("v2 decryption request", zap.String("request_id", requestID))
+		logging.L(logging.ComponentPlugin).Info("v2 decryption request", zap.String("request_id", requestID))
 	}
 
 	return &pb.DecryptResponse{
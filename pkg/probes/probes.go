@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"go.uber.org/zap"
 )
 
@@ -26,7 +27,7 @@ func HealthZ(prober []Prober) http.HandlerFunc {
 				w.WriteHeader(http.StatusInternalServerError)
 				fmt.Fprint(w, err)
 
-				zap.L().Error("health check failed", zap.Error(err))
+				logging.L(logging.ComponentProbes).Error("health check failed", zap.Error(err))
 
 				return
 			}
@@ -35,6 +36,6 @@ func HealthZ(prober []Prober) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, http.StatusText(http.StatusOK))
 
-		zap.L().Debug("health checks succeeded")
+		logging.L(logging.ComponentProbes).Debug("health checks succeeded")
 	}
 }
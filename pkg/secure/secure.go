@@ -0,0 +1,71 @@
+// Package secure provides helpers to limit the exposure of key material in memory.
+package secure
+
+import (
+	"errors"
+	"io"
+	"runtime"
+)
+
+const (
+	minReadSize = 512
+	maxSizeHint = 1 << 20
+)
+
+var errUnsupported = errors.New("not supported on this platform")
+
+// Wipe overwrites the buffers with zeros.
+func Wipe(bufs ...[]byte) {
+	for _, b := range bufs {
+		clear(b)
+	}
+
+	// prevent the compiler from eliminating the writes to buffers that are not used afterwards
+	runtime.KeepAlive(bufs)
+}
+
+// IsZero reports whether the buffer only contains zeros.
+func IsZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadAll reads r until EOF like io.ReadAll, but wipes the intermediate buffers when growing.
+// sizeHint is the expected size, e.g. the Content-Length of a response, values <= 0 are ignored.
+func ReadAll(r io.Reader, sizeHint int64) ([]byte, error) {
+	size := minReadSize
+	if sizeHint > 0 && sizeHint < maxSizeHint {
+		// one more byte to detect EOF without growing
+		size = int(sizeHint) + 1
+	}
+
+	buf := make([]byte, 0, size)
+
+	for {
+		if len(buf) == cap(buf) {
+			grown := make([]byte, len(buf), 2*cap(buf))
+			copy(grown, buf)
+			Wipe(buf)
+
+			buf = grown
+		}
+
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		if errors.Is(err, io.EOF) {
+			return buf, nil
+		}
+
+		if err != nil {
+			Wipe(buf)
+
+			return nil, err
+		}
+	}
+}
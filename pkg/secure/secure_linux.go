@@ -0,0 +1,35 @@
+//go:build linux
+
+package secure
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockMemory locks all current and future memory of the process into RAM, so that key material is never swapped to disk.
+// This requires the CAP_IPC_LOCK capability or a sufficient RLIMIT_MEMLOCK.
+func LockMemory() error {
+	err := unix.Mlockall(unix.MCL_CURRENT | unix.MCL_FUTURE)
+	if err != nil {
+		return fmt.Errorf("failed to lock memory (requires CAP_IPC_LOCK): %w", err)
+	}
+
+	return nil
+}
+
+// DisableCoreDumps prevents core dumps and ptrace attachment by unprivileged processes, which would expose key material.
+func DisableCoreDumps() error {
+	err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0})
+	if err != nil {
+		return fmt.Errorf("failed to set core dump limit: %w", err)
+	}
+
+	err = unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package secure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestDisableCoreDumps(t *testing.T) {
+	require.NoError(t, DisableCoreDumps())
+
+	dumpable, err := unix.PrctlRetInt(unix.PR_GET_DUMPABLE, 0, 0, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, dumpable)
+
+	var limit unix.Rlimit
+
+	require.NoError(t, unix.Getrlimit(unix.RLIMIT_CORE, &limit))
+	require.Zero(t, limit.Cur)
+}
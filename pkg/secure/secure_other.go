@@ -0,0 +1,13 @@
+//go:build !linux
+
+package secure
+
+// LockMemory is only supported on Linux.
+func LockMemory() error {
+	return errUnsupported
+}
+
+// DisableCoreDumps is only supported on Linux.
+func DisableCoreDumps() error {
+	return errUnsupported
+}
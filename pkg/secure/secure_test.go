@@ -0,0 +1,34 @@
+package secure
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWipe(t *testing.T) {
+	a, b := []byte("secret"), []byte("another secret")
+
+	Wipe(a, b, nil)
+
+	require.True(t, IsZero(a))
+	require.True(t, IsZero(b))
+	require.Len(t, a, len("secret"))
+}
+
+func TestReadAll(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	for _, hint := range []int64{-1, 0, 10, int64(len(data)), 1 << 30} {
+		got, err := ReadAll(iotest.OneByteReader(bytes.NewReader(data)), hint)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+
+	_, err := ReadAll(io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errors.New("failed"))), 0)
+	require.Error(t, err)
+}
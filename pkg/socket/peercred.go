@@ -0,0 +1,192 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+var errPeerCredentialsUnsupported = errors.New("peer credentials are only supported on linux")
+
+// PeerCredentials are the credentials of the process connected to the socket.
+type PeerCredentials struct {
+	PID        int32
+	UID        uint32
+	GID        uint32
+	Executable string
+}
+
+// PeerAllowList specifies which processes are allowed to connect to the socket.
+// A peer is allowed, if its UID or GID is listed (when any UIDs or GIDs are configured)
+// and its executable is listed (when any executables are configured).
+type PeerAllowList struct {
+	UIDs        []uint32
+	GIDs        []uint32
+	Executables []string
+}
+
+// ParsePeerAllowList parses comma separated lists of UIDs, GIDs and executable paths.
+// It returns nil, if all lists are empty.
+func ParsePeerAllowList(uids, gids, executables string) (*PeerAllowList, error) {
+	allow := &PeerAllowList{}
+
+	for _, id := range splitList(uids) {
+		uid, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid %q: %w", id, err)
+		}
+
+		allow.UIDs = append(allow.UIDs, uint32(uid))
+	}
+
+	for _, id := range splitList(gids) {
+		gid, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %q: %w", id, err)
+		}
+
+		allow.GIDs = append(allow.GIDs, uint32(gid))
+	}
+
+	for _, exe := range splitList(executables) {
+		if !filepath.IsAbs(exe) {
+			return nil, fmt.Errorf("executable %q must be an absolute path", exe)
+		}
+
+		allow.Executables = append(allow.Executables, filepath.Clean(exe))
+	}
+
+	if allow.empty() {
+		return nil, nil //nolint: nilnil
+	}
+
+	return allow, nil
+}
+
+func (a *PeerAllowList) empty() bool {
+	return a == nil || (len(a.UIDs) == 0 && len(a.GIDs) == 0 && len(a.Executables) == 0)
+}
+
+// check returns the reason why a peer is rejected or an empty string if the peer is allowed.
+func (a *PeerAllowList) check(creds *PeerCredentials) string {
+	if len(a.UIDs) > 0 || len(a.GIDs) > 0 {
+		if !slices.Contains(a.UIDs, creds.UID) && !slices.Contains(a.GIDs, creds.GID) {
+			return "uid_gid"
+		}
+	}
+
+	if len(a.Executables) > 0 && !slices.Contains(a.Executables, creds.Executable) {
+		return "executable"
+	}
+
+	return ""
+}
+
+// PeerAddr is the remote address of connections accepted by a listener with WithPeerCredentials.
+type PeerAddr struct {
+	net.Addr
+
+	// Credentials of the connected process, nil if they could not be read.
+	Credentials *PeerCredentials
+}
+
+type peerConn struct {
+	net.Conn
+
+	addr *PeerAddr
+}
+
+// RemoteAddr returns the address of the peer including its credentials.
+func (c *peerConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// peerListener rejects connections of peers, that are not in the allow list, before any RPC is processed.
+// If credentials is set, the credentials of accepted peers are attached to their connections.
+type peerListener struct {
+	net.Listener
+
+	allow       *PeerAllowList
+	credentials bool
+	provider    string
+}
+
+// Accept waits for the next connection of an allowed peer.
+func (l *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.allow.empty() {
+			// credentials are only recorded, failing to read them does not reject the peer
+			creds, _ := peerCredentials(conn, true)
+
+			return l.wrap(conn, creds), nil
+		}
+
+		reason := "credentials"
+
+		creds, err := peerCredentials(conn, len(l.allow.Executables) > 0)
+		if err == nil {
+			reason = l.allow.check(creds)
+			if reason == "" {
+				return l.wrap(conn, creds), nil
+			}
+		}
+
+		metrics.SocketRejectedConnectionsTotal.WithLabelValues(l.provider, reason).Inc()
+
+		fields := []zap.Field{zap.String("provider", l.provider), zap.String("reason", reason)}
+		if creds != nil {
+			fields = append(fields,
+				zap.Int32("pid", creds.PID),
+				zap.Uint32("uid", creds.UID),
+				zap.Uint32("gid", creds.GID),
+				zap.String("executable", creds.Executable),
+			)
+		}
+
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+
+		logging.L(logging.ComponentSocket).Warn("rejected connection to socket", fields...)
+
+		_ = conn.Close()
+	}
+}
+
+func (l *peerListener) wrap(conn net.Conn, creds *PeerCredentials) net.Conn {
+	if !l.credentials {
+		return conn
+	}
+
+	if creds != nil && creds.Executable == "" {
+		// resolving the executable is best effort, the process may already have exited
+		creds.Executable, _ = executable(creds.PID)
+	}
+
+	return &peerConn{Conn: conn, addr: &PeerAddr{Addr: conn.RemoteAddr(), Credentials: creds}}
+}
+
+func splitList(s string) []string {
+	var items []string
+
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const peerCredentialsSupported = true
+
+// peerCredentials reads the credentials of the connected process using SO_PEERCRED.
+func peerCredentials(conn net.Conn, resolveExecutable bool) (*PeerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("connection is not a unix socket connection")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ucred   *unix.Ucred
+		credErr error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if credErr != nil {
+		return nil, fmt.Errorf("failed to read peer credentials: %w", credErr)
+	}
+
+	creds := &PeerCredentials{
+		PID: ucred.Pid,
+		UID: ucred.Uid,
+		GID: ucred.Gid,
+	}
+
+	if resolveExecutable {
+		creds.Executable, err = executable(ucred.Pid)
+		if err != nil {
+			return creds, fmt.Errorf("failed to resolve peer executable: %w", err)
+		}
+	}
+
+	return creds, nil
+}
+
+// errPeerPIDNotVisible is returned for peers in a PID namespace, that is not visible to the plugin.
+var errPeerPIDNotVisible = errors.New("peer pid is not visible in the pid namespace of the plugin, resolving executables requires hostPID")
+
+// executable returns the path of the executable of a process.
+// The PID may be reused once the process exited, so the result is only reliable while the peer is connected.
+func executable(pid int32) (string, error) {
+	// SO_PEERCRED reports pid 0 for peers in a PID namespace that is not visible to the plugin
+	if pid <= 0 {
+		return "", errPeerPIDNotVisible
+	}
+
+	return os.Readlink("/proc/" + strconv.Itoa(int(pid)) + "/exe")
+}
@@ -0,0 +1,155 @@
+//go:build linux
+
+package socket
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// accepted dials the socket and reports whether the connection is kept open by the listener.
+func accepted(t *testing.T, listener net.Listener, path string) bool {
+	t.Helper()
+
+	conns := make(chan net.Conn, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	select {
+	case c := <-conns:
+		_ = c.Close()
+
+		return true
+	case <-time.After(200 * time.Millisecond):
+		// rejected connections are closed by the listener
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, err = conn.Read(make([]byte, 1))
+		require.Error(t, err)
+
+		return false
+	}
+}
+
+func TestPeerAllowList(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	exe, err = filepath.EvalSymlinks(exe)
+	require.NoError(t, err)
+
+	uid := uint32(os.Getuid()) //nolint: gosec
+	gid := uint32(os.Getgid()) //nolint: gosec
+
+	testCases := []struct {
+		name    string
+		allow   *PeerAllowList
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "uid allowed",
+			allow:   &PeerAllowList{UIDs: []uint32{uid}},
+			allowed: true,
+		},
+		{
+			name:    "gid allowed",
+			allow:   &PeerAllowList{UIDs: []uint32{uid + 1}, GIDs: []uint32{gid}},
+			allowed: true,
+		},
+		{
+			name:   "uid and gid rejected",
+			allow:  &PeerAllowList{UIDs: []uint32{uid + 1}, GIDs: []uint32{gid + 1}},
+			reason: "uid_gid",
+		},
+		{
+			name:    "executable allowed",
+			allow:   &PeerAllowList{UIDs: []uint32{uid}, Executables: []string{exe}},
+			allowed: true,
+		},
+		{
+			name:   "executable rejected",
+			allow:  &PeerAllowList{Executables: []string{"/usr/bin/kube-apiserver"}},
+			reason: "executable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Socket{Network: "unix", Path: filepath.Join(t.TempDir(), "kms.socket")}
+
+			listener, err := s.Listen(false, WithPeerAllowList(tc.allow))
+			require.NoError(t, err)
+
+			defer listener.Close()
+
+			var before float64
+			if tc.reason != "" {
+				before = testutil.ToFloat64(metrics.SocketRejectedConnectionsTotal.WithLabelValues(metrics.DefaultProvider, tc.reason))
+			}
+
+			require.Equal(t, tc.allowed, accepted(t, listener, s.Path))
+
+			if tc.reason != "" {
+				require.Eventually(t, func() bool {
+					return testutil.ToFloat64(metrics.SocketRejectedConnectionsTotal.WithLabelValues(metrics.DefaultProvider, tc.reason)) == before+1
+				}, time.Second, 10*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestPeerCredentials(t *testing.T) {
+	s := &Socket{Network: "unix", Path: filepath.Join(t.TempDir(), "kms.socket")}
+
+	listener, err := s.Listen(false, WithPeerCredentials())
+	require.NoError(t, err)
+
+	defer listener.Close()
+
+	go func() {
+		conn, err := net.Dial("unix", s.Path)
+		if err == nil {
+			defer conn.Close()
+
+			time.Sleep(time.Second)
+		}
+	}()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	addr, ok := conn.RemoteAddr().(*PeerAddr)
+	require.True(t, ok)
+	require.NotNil(t, addr.Credentials)
+	require.Equal(t, int32(os.Getpid()), addr.Credentials.PID)  //nolint: gosec
+	require.Equal(t, uint32(os.Getuid()), addr.Credentials.UID) //nolint: gosec
+	require.NotEmpty(t, addr.Credentials.Executable)
+}
+
+func TestExecutableRejectsInvisiblePID(t *testing.T) {
+	_, err := executable(0)
+	require.ErrorIs(t, err, errPeerPIDNotVisible)
+
+	exe, err := executable(int32(os.Getpid())) //nolint: gosec
+	require.NoError(t, err)
+	require.NotEmpty(t, exe)
+}
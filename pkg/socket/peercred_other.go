@@ -0,0 +1,17 @@
+//go:build !linux
+
+package socket
+
+import (
+	"net"
+)
+
+const peerCredentialsSupported = false
+
+func peerCredentials(_ net.Conn, _ bool) (*PeerCredentials, error) {
+	return nil, errPeerCredentialsUnsupported
+}
+
+func executable(_ int32) (string, error) {
+	return "", errPeerCredentialsUnsupported
+}
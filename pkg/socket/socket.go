@@ -6,26 +6,54 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
 	"go.uber.org/zap"
 )
 
-// Socket represents a unix socket.
+const (
+	// parentDirMode is used when creating the parent directory of the socket.
+	parentDirMode = 0o750
+
+	// dialTimeout is used to check whether an existing socket is still in use.
+	dialTimeout = time.Second
+)
+
+const (
+	networkUnix = "unix"
+	networkTCP  = "tcp"
+)
+
+// Socket represents a unix socket or a TCP address.
 type Socket struct {
 	Network string
 	Path    string
 }
 
-// NewSocket returns a new unix socket.
+// NewSocket returns a new unix socket (unix:///path) or TCP address (tcp://host:port).
 func NewSocket(str string) (*Socket, error) {
 	socket := &Socket{}
 
-	if strings.HasPrefix(strings.ToLower(str), "unix://") {
-		//nolint: mnd
-		s := strings.SplitN(str, "://", 2)
-		if s[1] != "" {
-			socket.Network, socket.Path = s[0], s[1]
+	//nolint: mnd
+	s := strings.SplitN(str, "://", 2)
+	if len(s) == 2 && s[1] != "" {
+		switch network := strings.ToLower(s[0]); network {
+		case networkUnix:
+			socket.Network, socket.Path = network, s[1]
+		case networkTCP:
+			_, port, err := net.SplitHostPort(s[1])
+			if err != nil || port == "" {
+				return nil, fmt.Errorf("invalid tcp address %q", s[1])
+			}
+
+			socket.Network, socket.Path = network, s[1]
 		}
 	}
 
@@ -36,24 +64,269 @@ func NewSocket(str string) (*Socket, error) {
 	return socket, nil
 }
 
+// IsTCP reports whether the socket is a TCP address.
+func (s *Socket) IsTCP() bool {
+	return s.Network == networkTCP
+}
+
+// ListenOption configures the listener returned by Listen.
+type ListenOption func(*listenOptions)
+
+type listenOptions struct {
+	provider    string
+	allow       *PeerAllowList
+	credentials bool
+	mode        os.FileMode
+	uid         int
+	gid         int
+}
+
+// WithPeerAllowList only accepts connections of processes matching the allow list, nil accepts all connections.
+func WithPeerAllowList(allow *PeerAllowList) ListenOption {
+	return func(o *listenOptions) {
+		o.allow = allow
+	}
+}
+
+// WithPeerCredentials records the credentials of the connected process. They are available
+// as *PeerAddr from the RemoteAddr of accepted connections. Only supported for unix sockets on linux,
+// on other platforms and for TCP addresses the option is ignored.
+func WithPeerCredentials() ListenOption {
+	return func(o *listenOptions) {
+		o.credentials = true
+	}
+}
+
+// WithProvider sets the name of the KMS provider served on the socket, used as metrics label.
+func WithProvider(provider string) ListenOption {
+	return func(o *listenOptions) {
+		o.provider = provider
+	}
+}
+
+// WithMode sets the file mode of the socket, 0 keeps the mode resulting from the umask.
+func WithMode(mode os.FileMode) ListenOption {
+	return func(o *listenOptions) {
+		o.mode = mode
+	}
+}
+
+// WithOwner sets the owner and group of the socket, -1 keeps the current owner or group.
+func WithOwner(uid, gid int) ListenOption {
+	return func(o *listenOptions) {
+		o.uid, o.gid = uid, gid
+	}
+}
+
 // Listen listens on the current socket for connections.
-func (s *Socket) Listen(force bool) (net.Listener, error) {
-	// Remove the socket file if it already exists.
-	_, err := os.Stat(s.Path)
-	if err == nil {
-		zap.L().Info("Socket already exists", zap.String("path", s.Path))
-
-		if force {
-			err = os.Remove(s.Path)
-			if err != nil {
-				return nil, fmt.Errorf("failed to remove unix socket: %w", err)
-			}
+// The parent directory is created if missing. An existing socket is only removed,
+// if no other process is listening on it or if force is set.
+// For TCP addresses the file options and the peer allow list do not apply.
+// nolint: cyclop
+func (s *Socket) Listen(force bool, opts ...ListenOption) (net.Listener, error) {
+	o := &listenOptions{provider: metrics.DefaultProvider, uid: -1, gid: -1}
+	for _, opt := range opts {
+		opt(o)
+	}
 
-			zap.L().Info("Socket overwrite is enabled. Successfully removed socket", zap.String("path", s.Path))
+	if s.IsTCP() {
+		if !o.allow.empty() {
+			return nil, errors.New("peer allow list is only supported for unix sockets")
 		}
+
+		listenConfig := net.ListenConfig{}
+
+		return listenConfig.Listen(context.Background(), s.Network, s.Path)
+	}
+
+	if !o.allow.empty() && !peerCredentialsSupported {
+		return nil, errPeerCredentialsUnsupported
+	}
+
+	err := os.MkdirAll(filepath.Dir(s.Path), parentDirMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	err = s.removeExisting(force)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := s.bind(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.allow.empty() || (o.credentials && peerCredentialsSupported) {
+		return &peerListener{Listener: listener, allow: o.allow, credentials: o.credentials, provider: o.provider}, nil
 	}
 
+	return listener, nil
+}
+
+// bind binds the socket in a private directory next to the socket path and moves it into place once its mode
+// and owner are set, so that the socket is never reachable with the mode resulting from the umask.
+func (s *Socket) bind(o *listenOptions) (net.Listener, error) {
+	// os.MkdirTemp creates the directory with mode 0700
+	dir, err := os.MkdirTemp(filepath.Dir(s.Path), ".kms-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "s")
+
 	listenConfig := net.ListenConfig{}
 
-	return listenConfig.Listen(context.Background(), s.Network, s.Path)
+	listener, err := listenConfig.Listen(context.Background(), s.Network, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the socket is moved, so it is removed by unlinkListener instead
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+
+	if o.mode != 0 {
+		err = os.Chmod(path, o.mode)
+		if err != nil {
+			listener.Close()
+
+			return nil, fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+
+	if o.uid != -1 || o.gid != -1 {
+		err = os.Lchown(path, o.uid, o.gid)
+		if err != nil {
+			listener.Close()
+
+			return nil, fmt.Errorf("failed to set socket owner: %w", err)
+		}
+	}
+
+	err = os.Rename(path, s.Path)
+	if err != nil {
+		listener.Close()
+
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+
+	return &unlinkListener{Listener: listener, path: s.Path}, nil
+}
+
+// unlinkListener removes the socket file once the listener is closed.
+type unlinkListener struct {
+	net.Listener
+
+	path   string
+	unlink sync.Once
+}
+
+func (l *unlinkListener) Close() error {
+	err := l.Listener.Close()
+
+	l.unlink.Do(func() {
+		_ = os.Remove(l.path)
+	})
+
+	return err
+}
+
+// removeExisting removes a stale socket left behind by a crashed plugin.
+// Sockets still in use and other files are only removed if force is set.
+func (s *Socket) removeExisting(force bool) error {
+	fi, err := os.Lstat(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to stat unix socket: %w", err)
+	}
+
+	logging.L(logging.ComponentSocket).Info("Socket already exists", zap.String("path", s.Path))
+
+	switch {
+	case force:
+		logging.L(logging.ComponentSocket).Info("Socket overwrite is enabled. Removing socket", zap.String("path", s.Path))
+	case fi.Mode()&os.ModeSocket == 0:
+		return fmt.Errorf("%s exists and is not a unix socket", s.Path)
+	case s.inUse():
+		return fmt.Errorf("%s is in use by another process", s.Path)
+	default:
+		logging.L(logging.ComponentSocket).Info("Socket is stale. Removing socket", zap.String("path", s.Path))
+	}
+
+	err = os.Remove(s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to remove unix socket: %w", err)
+	}
+
+	return nil
+}
+
+// inUse reports whether another process accepts connections on the socket.
+func (s *Socket) inUse() bool {
+	conn, err := net.DialTimeout(s.Network, s.Path, dialTimeout)
+	if err != nil {
+		return false
+	}
+
+	_ = conn.Close()
+
+	return true
+}
+
+// ParseMode parses an octal file mode such as "0600", an empty string returns 0.
+func ParseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode %q", mode)
+	}
+
+	return os.FileMode(m), nil
+}
+
+// LookupUser returns the UID of a user name or numeric UID, an empty string returns -1.
+func LookupUser(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+
+	if uid, err := strconv.Atoi(name); err == nil {
+		return uid, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(u.Uid)
+}
+
+// LookupGroup returns the GID of a group name or numeric GID, an empty string returns -1.
+func LookupGroup(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(g.Gid)
 }
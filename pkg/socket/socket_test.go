@@ -1,7 +1,9 @@
 package socket
 
 import (
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -24,11 +26,29 @@ func TestNewSocket(t *testing.T) {
 				Path:    "/opt/vaultkms.socket",
 			},
 		},
+		{
+			name: "tcp",
+			str:  "tcp://0.0.0.0:8443",
+			exp: &Socket{
+				Network: "tcp",
+				Path:    "0.0.0.0:8443",
+			},
+		},
+		{
+			name: "tcp without port",
+			str:  "tcp://0.0.0.0",
+			err:  true,
+		},
 		{
 			name: "invalid",
 			str:  "/opt/vaultkms.socket",
 			err:  true,
 		},
+		{
+			name: "unsupported network",
+			str:  "udp://0.0.0.0:8443",
+			err:  true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -64,3 +84,140 @@ func TestForce(t *testing.T) {
 		os.Remove(s.Path)
 	})
 }
+
+func TestParsePeerAllowList(t *testing.T) {
+	allow, err := ParsePeerAllowList("0, 1000", "", "/usr/local/bin/kube-apiserver")
+	require.NoError(t, err)
+	require.Equal(t, &PeerAllowList{
+		UIDs:        []uint32{0, 1000},
+		Executables: []string{"/usr/local/bin/kube-apiserver"},
+	}, allow)
+
+	allow, err = ParsePeerAllowList("", "", "")
+	require.NoError(t, err)
+	require.Nil(t, allow)
+
+	_, err = ParsePeerAllowList("root", "", "")
+	require.Error(t, err)
+
+	_, err = ParsePeerAllowList("", "", "kube-apiserver")
+	require.Error(t, err)
+}
+
+func TestListenCreatesParentDirectory(t *testing.T) {
+	s := &Socket{"unix", filepath.Join(t.TempDir(), "kms", "vaultkms.socket")}
+
+	listener, err := s.Listen(false, WithMode(0o600), WithOwner(os.Getuid(), os.Getgid()))
+	require.NoError(t, err)
+
+	dir, err := os.Stat(filepath.Dir(s.Path))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(parentDirMode), dir.Mode().Perm())
+
+	fi, err := os.Stat(s.Path)
+	require.NoError(t, err)
+	require.NotZero(t, fi.Mode()&os.ModeSocket)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// the private directory the socket was bound in is removed
+	entries, err := os.ReadDir(filepath.Dir(s.Path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "vaultkms.socket", entries[0].Name())
+
+	conn, err := net.Dial(s.Network, s.Path)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// the socket is removed on shutdown
+	require.NoError(t, listener.Close())
+
+	_, err = os.Stat(s.Path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListenExistingSocket(t *testing.T) {
+	t.Run("stale socket is removed", func(t *testing.T) {
+		s := &Socket{"unix", filepath.Join(t.TempDir(), "vaultkms.socket")}
+
+		// simulate a crashed plugin leaving the socket behind
+		stale, err := net.Listen(s.Network, s.Path)
+		require.NoError(t, err)
+
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, stale.Close())
+
+		listener, err := s.Listen(false)
+		require.NoError(t, err)
+		require.NoError(t, listener.Close())
+	})
+
+	t.Run("socket in use is kept", func(t *testing.T) {
+		s := &Socket{"unix", filepath.Join(t.TempDir(), "vaultkms.socket")}
+
+		inUse, err := s.Listen(false)
+		require.NoError(t, err)
+
+		defer inUse.Close()
+
+		_, err = s.Listen(false)
+		require.ErrorContains(t, err, "in use by another process")
+	})
+
+	t.Run("regular file is kept", func(t *testing.T) {
+		s := &Socket{"unix", filepath.Join(t.TempDir(), "vaultkms.socket")}
+
+		require.NoError(t, os.WriteFile(s.Path, []byte("data"), 0o600))
+
+		_, err := s.Listen(false)
+		require.ErrorContains(t, err, "is not a unix socket")
+
+		data, err := os.ReadFile(s.Path)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(data))
+	})
+}
+
+func TestListenTCP(t *testing.T) {
+	s := &Socket{"tcp", "127.0.0.1:0"}
+
+	listener, err := s.Listen(false, WithMode(0o600))
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	_, err = s.Listen(false, WithPeerAllowList(&PeerAllowList{UIDs: []uint32{0}}))
+	require.Error(t, err)
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("0660")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o660), mode)
+
+	mode, err = ParseMode("")
+	require.NoError(t, err)
+	require.Zero(t, mode)
+
+	_, err = ParseMode("rw-rw----")
+	require.Error(t, err)
+
+	_, err = ParseMode("17777")
+	require.Error(t, err)
+}
+
+func TestLookupUserAndGroup(t *testing.T) {
+	uid, err := LookupUser("1000")
+	require.NoError(t, err)
+	require.Equal(t, 1000, uid)
+
+	uid, err = LookupUser("")
+	require.NoError(t, err)
+	require.Equal(t, -1, uid)
+
+	gid, err := LookupGroup("0")
+	require.NoError(t, err)
+	require.Zero(t, gid)
+
+	_, err = LookupUser("vault-kms-user-that-does-not-exist")
+	require.Error(t, err)
+}
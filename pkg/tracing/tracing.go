@@ -0,0 +1,80 @@
+// Package tracing configures OpenTelemetry tracing of KMS requests and the Vault requests they cause.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the instrumentation scope of all spans of the plugin.
+	TracerName = "github.com/FalcoSuessgott/vault-kubernetes-kms"
+
+	serviceName = "vault-kubernetes-kms"
+)
+
+// Config configures the OTLP exporter.
+type Config struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver, empty disables tracing.
+	Endpoint string
+
+	// Insecure disables TLS for the connection to the receiver.
+	Insecure bool
+
+	// SampleRatio is the fraction of traces sampled, unless the parent span was sampled.
+	SampleRatio float64
+
+	// Version is reported as service.version.
+	Version string
+}
+
+// Setup installs a tracer provider exporting spans via OTLP and the W3C trace context propagator.
+// Without an endpoint the global no-op tracer provider is kept. The returned function flushes
+// and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(cfg.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the tracer of the plugin, a no-op tracer unless Setup configured an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetup(t *testing.T) {
+	global, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(global)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	shutdown, err := Setup(t.Context(), Config{})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(t.Context()))
+	require.Equal(t, global, otel.GetTracerProvider(), "no-op without endpoint")
+
+	_, span := Tracer().Start(t.Context(), "noop")
+	require.False(t, span.IsRecording())
+
+	// the exporter connects lazily, nothing is exported as no trace is sampled
+	shutdown, err = Setup(t.Context(), Config{Endpoint: "127.0.0.1:4317", Insecure: true, SampleRatio: 0, Version: "1.0.0"})
+	require.NoError(t, err)
+
+	require.IsType(t, &sdktrace.TracerProvider{}, otel.GetTracerProvider())
+
+	_, span = Tracer().Start(t.Context(), "not sampled")
+	require.False(t, span.IsRecording())
+	span.End()
+
+	require.NoError(t, shutdown(t.Context()))
+}
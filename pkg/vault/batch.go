@@ -0,0 +1,318 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	defaultBatchMaxSize = 128
+
+	// defaultBatchTimeout bounds batches of callers without deadline, if the client has no timeout.
+	defaultBatchTimeout = time.Minute
+)
+
+// BatchItemError is the error of a single item of a transit batch call. Vault reports failed items with
+// partial_failure_response_code instead of their own status, so StatusCode is the status Vault responds with
+// for a single request failing this way.
+type BatchItemError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *BatchItemError) Error() string {
+	return e.Message
+}
+
+// batchRequest is a single transit operation waiting to be sent as part of a batch.
+type batchRequest struct {
+	ctx    context.Context //nolint: containedctx
+	input  map[string]any
+	result chan batchResult
+}
+
+// batchResult is the outcome of a single item of a batch call.
+type batchResult struct {
+	value      string
+	keyVersion string
+	requestID  string
+	err        error
+}
+
+// batchFunc sends all inputs as one batch_input call and returns one result per input.
+type batchFunc func(ctx context.Context, inputs []map[string]any) ([]batchResult, error)
+
+// batcher gathers concurrent transit requests that arrive within a small window
+// and sends them to Vault as a single batch_input call.
+type batcher struct {
+	operation string
+	window    time.Duration
+	maxSize   int
+	timeout   time.Duration
+	send      batchFunc
+
+	mu      sync.Mutex
+	pending []*batchRequest
+	timer   *time.Timer
+}
+
+// newBatcher returns a batcher sending batches with send. Batches of callers without deadline time out after timeout.
+func newBatcher(operation string, window time.Duration, maxSize int, timeout time.Duration, send batchFunc) *batcher {
+	if maxSize <= 0 {
+		maxSize = defaultBatchMaxSize
+	}
+
+	if timeout <= 0 {
+		timeout = defaultBatchTimeout
+	}
+
+	return &batcher{
+		operation: operation,
+		window:    window,
+		maxSize:   maxSize,
+		timeout:   timeout,
+		send:      send,
+	}
+}
+
+// submit queues the input and blocks until its batch has been sent or the context is done.
+func (b *batcher) submit(ctx context.Context, input map[string]any) batchResult {
+	req := &batchRequest{
+		ctx:    ctx,
+		input:  input,
+		result: make(chan batchResult, 1),
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, req)
+
+	switch {
+	case len(b.pending) >= b.maxSize:
+		batch := b.take()
+		b.mu.Unlock()
+
+		go b.flush(batch)
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.window, b.flushPending)
+		b.mu.Unlock()
+	default:
+		b.mu.Unlock()
+	}
+
+	select {
+	case res := <-req.result:
+		return res
+	case <-ctx.Done():
+		return batchResult{err: ctx.Err()}
+	}
+}
+
+// take removes all pending requests, the caller must hold the lock.
+func (b *batcher) take() []*batchRequest {
+	batch := b.pending
+	b.pending = nil
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	return batch
+}
+
+func (b *batcher) flushPending() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.flush(batch)
+	}
+}
+
+func (b *batcher) flush(batch []*batchRequest) {
+	inputs := make([]map[string]any, 0, len(batch))
+	for _, req := range batch {
+		inputs = append(inputs, req.input)
+	}
+
+	metrics.VaultTransitBatchSize.WithLabelValues(metrics.Provider(batch[0].ctx), b.operation).Observe(float64(len(batch)))
+
+	ctx, cancel := b.context(batch)
+	defer cancel()
+
+	results, err := b.send(ctx, inputs)
+	if err == nil && len(results) != len(batch) {
+		err = fmt.Errorf("invalid batch response: expected %d results, got %d", len(batch), len(results))
+	}
+
+	for i, req := range batch {
+		if err != nil {
+			req.result <- batchResult{err: err}
+
+			continue
+		}
+
+		req.result <- results[i]
+	}
+}
+
+// context returns the context of a batch. The batch outlives any single caller, so it only keeps the values
+// of the first request context and expires at the latest deadline of the callers.
+func (b *batcher) context(batch []*batchRequest) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+
+	for _, req := range batch {
+		d, ok := req.ctx.Deadline()
+		if !ok {
+			d = time.Now().Add(b.timeout)
+		}
+
+		if d.After(deadline) {
+			deadline = d
+		}
+	}
+
+	return context.WithDeadline(context.WithoutCancel(batch[0].ctx), deadline)
+}
+
+// WithBatching enables micro-batching of concurrent encrypt and decrypt requests.
+// Requests arriving within the window are sent to Vault as a single batch_input call,
+// a batch is sent early once it reaches maxSize items. A window of 0 disables batching.
+func WithBatching(window time.Duration, maxSize int) Option {
+	return func(c *Client) error {
+		if window <= 0 {
+			return nil
+		}
+
+		c.batchWindow, c.batchMaxSize = window, maxSize
+		c.encryptBatcher = newBatcher("encrypt", window, maxSize, c.ClientTimeout(), c.encryptBatch)
+		c.decryptBatcher = newBatcher("decrypt", window, maxSize, c.ClientTimeout(), c.decryptBatch)
+
+		return nil
+	}
+}
+
+func (c *Client) encryptBatch(ctx context.Context, inputs []map[string]any) ([]batchResult, error) {
+	items, err := c.writeBatch(ctx, fmt.Sprintf(encryptDataPath, c.TransitEngine, c.TransitKey), inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]batchResult, 0, len(items))
+
+	for _, item := range items {
+		if item.err != nil {
+			results = append(results, batchResult{requestID: item.requestID, err: item.err})
+
+			continue
+		}
+
+		ciphertext, ok := item.data["ciphertext"].(string)
+		if !ok {
+			results = append(results, batchResult{requestID: item.requestID, err: errors.New("invalid response")})
+
+			continue
+		}
+
+		res := batchResult{value: ciphertext, requestID: item.requestID}
+
+		if kv, ok := item.data["key_version"].(json.Number); ok {
+			res.keyVersion = kv.String()
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (c *Client) decryptBatch(ctx context.Context, inputs []map[string]any) ([]batchResult, error) {
+	items, err := c.writeBatch(ctx, fmt.Sprintf(decryptDataPath, c.TransitEngine, c.TransitKey), inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]batchResult, 0, len(items))
+
+	for _, item := range items {
+		if item.err != nil {
+			results = append(results, batchResult{requestID: item.requestID, err: item.err})
+
+			continue
+		}
+
+		plaintext, ok := item.data["plaintext"].(string)
+		if !ok {
+			results = append(results, batchResult{requestID: item.requestID, err: errors.New("invalid response")})
+
+			continue
+		}
+
+		results = append(results, batchResult{value: plaintext, requestID: item.requestID})
+	}
+
+	return results, nil
+}
+
+type batchItem struct {
+	data      map[string]any
+	requestID string
+	err       error
+}
+
+// writeBatch performs a transit batch call and returns the raw batch_results.
+// https://developer.hashicorp.com/vault/api-docs/secret/transit#batch_input
+func (c *Client) writeBatch(ctx context.Context, path string, inputs []map[string]any) ([]batchItem, error) {
+	input := map[string]any{
+		"batch_input": inputs,
+		// return per-item errors instead of failing the whole batch when only some items failed
+		"partial_failure_response_code": http.StatusOK,
+	}
+
+	resp, err := withTokenRetry(ctx, c, func() (*api.Secret, error) {
+		return c.logical().WriteWithContext(ctx, path, input)
+	})
+	if err != nil {
+		return nil, wrapServerStateErr(err)
+	}
+
+	if resp == nil {
+		return nil, errors.New("invalid response")
+	}
+
+	raw, ok := resp.Data["batch_results"].([]any)
+	if !ok {
+		return nil, errors.New("invalid batch response")
+	}
+
+	items := make([]batchItem, 0, len(raw))
+
+	for _, r := range raw {
+		data, ok := r.(map[string]any)
+		if !ok {
+			items = append(items, batchItem{requestID: resp.RequestID, err: errors.New("invalid batch response")})
+
+			continue
+		}
+
+		if msg, ok := data["error"].(string); ok && msg != "" {
+			// failed items are invalid inputs, Vault fails the whole batch on other errors
+			items = append(items, batchItem{requestID: resp.RequestID, err: &BatchItemError{StatusCode: http.StatusBadRequest, Message: msg}})
+
+			continue
+		}
+
+		items = append(items, batchItem{data: data, requestID: resp.RequestID})
+	}
+
+	return items, nil
+}
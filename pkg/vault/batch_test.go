@@ -0,0 +1,302 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault is a minimal Vault API used for unit tests that don't require a vault container.
+type fakeVault struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]http.HandlerFunc
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	t.Helper()
+
+	fv := &fakeVault{handlers: map[string]http.HandlerFunc{}}
+
+	fv.Handle("/v1/auth/token/lookup-self", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data": map[string]any{"ttl": 3600, "creation_ttl": 3600},
+		})
+	})
+
+	fv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fv.mu.Lock()
+		h, ok := fv.handlers[r.URL.Path]
+		fv.mu.Unlock()
+
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{"no handler for " + r.URL.Path}})
+
+			return
+		}
+
+		h(w, r)
+	}))
+
+	t.Cleanup(fv.Close)
+
+	return fv
+}
+
+func (fv *fakeVault) Handle(path string, h http.HandlerFunc) {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+
+	fv.handlers[path] = h
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+type batchRequestBody struct {
+	BatchInput                 []map[string]string `json:"batch_input"`
+	PartialFailureResponseCode int                 `json:"partial_failure_response_code"`
+}
+
+//nolint:funlen
+func TestBatchingCoalescesConcurrentRequests(t *testing.T) {
+	const callers = 5
+
+	fv := newFakeVault(t)
+
+	var encryptCalls, decryptCalls atomic.Int32
+
+	fv.Handle("/v1/transit/encrypt/kms", func(w http.ResponseWriter, r *http.Request) {
+		encryptCalls.Add(1)
+
+		var body batchRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, nil)
+
+			return
+		}
+
+		results := make([]map[string]any, 0, len(body.BatchInput))
+		for _, in := range body.BatchInput {
+			results = append(results, map[string]any{
+				"ciphertext":  "vault:v3:" + in["plaintext"],
+				"key_version": 3,
+			})
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"batch_results": results}})
+	})
+
+	fv.Handle("/v1/transit/decrypt/kms", func(w http.ResponseWriter, r *http.Request) {
+		decryptCalls.Add(1)
+
+		var body batchRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, nil)
+
+			return
+		}
+
+		results := make([]map[string]any, 0, len(body.BatchInput))
+		for _, in := range body.BatchInput {
+			results = append(results, map[string]any{
+				"plaintext": strings.TrimPrefix(in["ciphertext"], "vault:v3:"),
+			})
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"batch_results": results}})
+	})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+		WithBatching(100*time.Millisecond, 10),
+	)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := range callers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			plain := []byte(strings.Repeat("x", i+1))
+
+			enc, kv, err := vc.Encrypt(t.Context(), plain)
+			require.NoError(t, err)
+			require.Equal(t, "3", kv)
+			require.Equal(t, "vault:v3:"+base64.StdEncoding.EncodeToString(plain), string(enc))
+
+			time.Sleep(200 * time.Millisecond)
+
+			dec, err := vc.Decrypt(t.Context(), enc)
+			require.NoError(t, err)
+			require.Equal(t, plain, dec)
+		}()
+	}
+
+	wg.Wait()
+
+	require.EqualValues(t, 1, encryptCalls.Load(), "all encrypt requests should be sent as one batch")
+	require.EqualValues(t, 1, decryptCalls.Load(), "all decrypt requests should be sent as one batch")
+}
+
+func TestBatchingReturnsPerItemErrors(t *testing.T) {
+	fv := newFakeVault(t)
+
+	fv.Handle("/v1/transit/decrypt/kms", func(w http.ResponseWriter, r *http.Request) {
+		var body batchRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, nil)
+
+			return
+		}
+
+		if body.PartialFailureResponseCode != http.StatusOK {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"partial failure response code not set"}})
+
+			return
+		}
+
+		results := make([]map[string]any, 0, len(body.BatchInput))
+		for _, in := range body.BatchInput {
+			if in["ciphertext"] == "invalid" {
+				results = append(results, map[string]any{"error": "invalid ciphertext: no prefix"})
+
+				continue
+			}
+
+			results = append(results, map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte("plain"))})
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"batch_results": results}})
+	})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+		WithBatching(100*time.Millisecond, 10),
+	)
+	require.NoError(t, err)
+
+	var (
+		wg               sync.WaitGroup
+		validErr, badErr error
+		valid            []byte
+	)
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		valid, validErr = vc.Decrypt(t.Context(), []byte("vault:v1:valid"))
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		_, badErr = vc.Decrypt(t.Context(), []byte("invalid"))
+	}()
+
+	wg.Wait()
+
+	require.NoError(t, validErr)
+	require.Equal(t, []byte("plain"), valid)
+	require.EqualError(t, badErr, "invalid ciphertext: no prefix")
+
+	var itemErr *BatchItemError
+	require.ErrorAs(t, badErr, &itemErr)
+	require.Equal(t, http.StatusBadRequest, itemErr.StatusCode)
+}
+
+func TestBatchingFlushesWhenFull(t *testing.T) {
+	var sent atomic.Int32
+
+	b := newBatcher("encrypt", time.Hour, 2, 0, func(_ context.Context, inputs []map[string]any) ([]batchResult, error) {
+		sent.Add(1)
+
+		results := make([]batchResult, len(inputs))
+		for i := range inputs {
+			results[i] = batchResult{value: "ok"}
+		}
+
+		return results, nil
+	})
+
+	var wg sync.WaitGroup
+
+	for range 2 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res := b.submit(t.Context(), map[string]any{})
+			require.NoError(t, res.err)
+			require.Equal(t, "ok", res.value)
+		}()
+	}
+
+	wg.Wait()
+
+	require.EqualValues(t, 1, sent.Load(), "a full batch must be sent without waiting for the window")
+}
+
+func TestBatchExpiresAtLatestCallerDeadline(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+
+	b := newBatcher("encrypt", time.Hour, 2, time.Minute, func(ctx context.Context, inputs []map[string]any) ([]batchResult, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+
+		return make([]batchResult, len(inputs)), nil
+	})
+
+	early, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	late, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	lateDeadline, _ := late.Deadline()
+
+	var wg sync.WaitGroup
+
+	for _, ctx := range []context.Context{early, late} {
+		wg.Go(func() {
+			require.NoError(t, b.submit(ctx, map[string]any{}).err)
+		})
+	}
+
+	wg.Wait()
+	require.Equal(t, lateDeadline, <-deadlines)
+
+	// callers without deadline are bounded by the timeout
+	for range 2 {
+		wg.Go(func() {
+			require.NoError(t, b.submit(t.Context(), map[string]any{}).err)
+		})
+	}
+
+	wg.Wait()
+	require.WithinDuration(t, time.Now().Add(time.Minute), <-deadlines, 5*time.Second)
+}
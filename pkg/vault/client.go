@@ -1,11 +1,22 @@
 package vault
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sync/atomic"
+	"time"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/certs"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/events"
 	customHTTP "github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/http"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
 	"github.com/hashicorp/vault/api"
+	"go.uber.org/zap"
 )
 
 // Client Vault API wrapper.
@@ -36,6 +47,50 @@ type Client struct {
 
 	TransitEngine string
 	TransitKey    string
+
+	// namespace overrides the namespace of the client for transit requests.
+	namespace string
+
+	batchWindow    time.Duration
+	batchMaxSize   int
+	encryptBatcher *batcher
+	decryptBatcher *batcher
+
+	serverState *atomic.Pointer[ServerState]
+
+	transport *customHTTP.RoundTripper
+
+	// hardened wipes plaintext DEKs after use, see WithHardenedMemory.
+	hardened bool
+
+	// tlsConfig is the TLS config set by WithTLS, also used by the cert auth login.
+	tlsConfig *tls.Config
+
+	// events reports authentication, token, key and server state problems, see WithEvents.
+	events *events.Reporter
+
+	// keyVersion is the last observed version of the transit key, 0 if unknown.
+	keyVersion *atomic.Int64
+
+	// swapper serializes re-authentications and publishes new tokens, see Reauthenticate.
+	swapper *tokenSwapper
+}
+
+// TLSOptions configures the TLS connection to Vault.
+type TLSOptions struct {
+	// ClientCertFile and ClientKeyFile are presented to Vault listeners requiring mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// CACert is a PEM file or a directory of PEM files verifying Vault's certificate.
+	CACert string
+
+	// ServerName overrides the host name verified and sent via SNI.
+	ServerName string
+
+	// MinVersion defaults to TLS 1.2, CipherSuites to Go's defaults.
+	MinVersion   uint16
+	CipherSuites []uint16
 }
 
 // Option vault client connection option.
@@ -54,24 +109,44 @@ func NewClient(opts ...Option) (*Client, error) {
 		return nil, err
 	}
 
-	client := &Client{Client: c}
+	client := &Client{Client: c, serverState: &atomic.Pointer[ServerState]{}, keyVersion: &atomic.Int64{}, swapper: newTokenSwapper()}
+
+	if rt, ok := cfg.HttpClient.Transport.(*customHTTP.RoundTripper); ok {
+		client.transport = rt
+	}
 
 	for _, opt := range opts {
 		err = opt(client)
 		if err != nil {
-			return nil, err
+			return nil, client.withServerState(err)
 		}
 	}
 
 	// perform a self lookup to verify the token
 	_, err = c.Auth().Token().LookupSelf()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to vault: %w", err)
+		return nil, fmt.Errorf("failed to connect to vault: %w", client.withServerState(err))
+	}
+
+	_, err = client.CheckServerState(context.Background())
+	if err != nil {
+		logging.L(logging.ComponentVault).Warn("failed to check vault server state", zap.Error(err))
 	}
 
 	return client, nil
 }
 
+// withServerState checks the Vault server state and adds it to the error, so that
+// a sealed or uninitialized Vault is reported as such instead of an opaque error.
+func (c *Client) withServerState(err error) error {
+	state, checkErr := c.CheckServerState(context.Background())
+	if checkErr != nil || state.Err() == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", state.Err(), err)
+}
+
 // WithVaultAddress sets the specified address.
 func WithVaultAddress(address string) Option {
 	return func(c *Client) error {
@@ -90,6 +165,93 @@ func WithVaultNamespace(namespace string) Option {
 	}
 }
 
+// WithTLS configures the TLS connection to Vault. Client certificates and CA files are reloaded once they change.
+// The options are merged into the TLS config read from the environment, e.g. VAULT_SKIP_VERIFY, VAULT_CLIENT_CERT
+// or VAULT_TLS_SERVER_NAME, unset options keep the settings of the environment.
+// It must follow WithVaultAddress and precede the auth method option, which already connects to Vault.
+func WithTLS(opts TLSOptions) Option {
+	return func(c *Client) error {
+		var (
+			keyPair *certs.KeyPair
+			rootCAs *certs.CertPool
+			err     error
+		)
+
+		if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+			if opts.ClientCertFile == "" || opts.ClientKeyFile == "" {
+				return errors.New("vault client certificate and key are both required")
+			}
+
+			keyPair, err = certs.NewKeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+			if err != nil {
+				return fmt.Errorf("error loading vault client certificate: %w", err)
+			}
+		}
+
+		if c.transport == nil {
+			return errors.New("error configuring vault tls: unexpected transport")
+		}
+
+		transport, ok := c.transport.Transport.(*http.Transport)
+		if !ok {
+			return errors.New("error configuring vault tls: unexpected transport")
+		}
+
+		base := transport.TLSClientConfig
+
+		// like the Vault CLI, VAULT_SKIP_VERIFY disables the verification even if a CA is configured
+		skipVerify := base != nil && base.InsecureSkipVerify
+
+		if opts.CACert != "" && !skipVerify {
+			rootCAs, err = certs.NewCertPool(opts.CACert)
+			if err != nil {
+				return fmt.Errorf("error loading vault ca certificate: %w", err)
+			}
+		}
+
+		serverName := opts.ServerName
+		if serverName == "" && base != nil {
+			serverName = base.ServerName
+		}
+
+		// servers addressed by IP are verified against the address, since IP addresses are not sent via SNI
+		host := serverName
+		if host == "" {
+			if u, err := url.Parse(c.Address()); err == nil {
+				host = u.Hostname()
+			}
+		}
+
+		cfg := certs.ClientConfig(base, keyPair, rootCAs, host)
+		cfg.ServerName = serverName
+
+		if len(opts.CipherSuites) > 0 {
+			cfg.CipherSuites = opts.CipherSuites
+		}
+
+		if opts.MinVersion != 0 {
+			cfg.MinVersion = opts.MinVersion
+		}
+
+		transport.TLSClientConfig = cfg
+		c.tlsConfig = cfg
+
+		return nil
+	}
+}
+
+// WithRateLimit limits the number of requests per second sent to Vault, 0 only throttles after Vault responded with 429.
+// The limit shrinks whenever Vault responds with 429 Too Many Requests and recovers afterwards.
+func WithRateLimit(maxRate float64) Option {
+	return func(c *Client) error {
+		if c.transport != nil {
+			c.transport.Limiter = customHTTP.NewAdaptiveLimiter(maxRate)
+		}
+
+		return nil
+	}
+}
+
 // WithTransit sets transit parameters.
 func WithTransit(mount, key string) Option {
 	return func(c *Client) error {
@@ -100,7 +262,17 @@ func WithTransit(mount, key string) Option {
 	}
 }
 
-// WithTokenAuth sets the specified token.
+// WithEvents reports failed logins, expiring tokens, transit key rotations and changes of the Vault server health
+// as Kubernetes Events. It must precede the auth method option to report a failed initial login.
+func WithEvents(r *events.Reporter) Option {
+	return func(c *Client) error {
+		c.events = r
+
+		return nil
+	}
+}
+
+// WithTokenAuth sets the specified token. No login is performed, so token auth is not counted in the auth metrics.
 func WithTokenAuth(token string) Option {
 	return func(c *Client) error {
 		c.Token = token
@@ -139,6 +311,9 @@ func WithAppRoleAuth(mount, roleID, secretID string) Option {
 		}
 
 		s, err := c.Logical().Write(fmt.Sprintf(appRoleAuthLoginPath, mount), opts)
+
+		c.observeLogin("approle", err)
+
 		if err != nil {
 			return fmt.Errorf("error performing approle auth: %w", err)
 		}
@@ -168,6 +343,9 @@ func WithUserPassAuth(mount string, username string, password string) Option {
 			fmt.Sprintf(userPassAuthLoginPath, mount, url.PathEscape(username)),
 			opts,
 		)
+
+		c.observeLogin("userpass", err)
+
 		if err != nil {
 			return fmt.Errorf("error performing userpass auth: %w", err)
 		}
@@ -186,6 +364,9 @@ func WithUserPassAuth(mount string, username string, password string) Option {
 // The client certificate is presented during the TLS handshake; caFile should be the CA that
 // signed the Vault server's TLS certificate (for the HTTPS connection). Vault verifies the
 // client cert against cert roles configured with vault write auth/{mount}/certs/{name}.
+// If keyFile is empty, certFile must contain both the certificate and the private key
+// (e.g. kubelet-client-current.pem). The key pair is only kept in memory and reloaded
+// on re-authentication once the files changed.
 func WithCertAuth(mount, role, certFile, keyFile, caFile string) Option {
 	return func(c *Client) error {
 		c.CertAuthMount = mount
@@ -193,29 +374,29 @@ func WithCertAuth(mount, role, certFile, keyFile, caFile string) Option {
 		c.CertFile = certFile
 		c.CertKey = keyFile
 
-		// Build a temporary Vault SDK client configured with the client TLS certificate.
-		// The client cert is presented during the TLS handshake, so it must be on the transport.
-		tmpCfg := api.DefaultConfig()
-		tmpCfg.Address = c.Address()
-
-		err := tmpCfg.ConfigureTLS(&api.TLSConfig{
-			ClientCert: certFile,
-			ClientKey:  keyFile,
-			CACert:     caFile,
-		})
+		keyPair, err := certs.NewKeyPair(certFile, keyFile)
 		if err != nil {
-			return fmt.Errorf("error configuring TLS for cert auth: %w", err)
+			return fmt.Errorf("error loading client certificate for cert auth: %w", err)
 		}
 
-		tmpClient, err := api.NewClient(tmpCfg)
+		return withCertAuth(mount, role, keyPair, caFile)(c)
+	}
+}
+
+func withCertAuth(mount, role string, keyPair *certs.KeyPair, caFile string) Option {
+	return func(c *Client) error {
+		loginClient, err := newCertAuthClient(c.Address(), c.tlsConfig, keyPair, caFile)
 		if err != nil {
-			return fmt.Errorf("error creating cert auth client: %w", err)
+			return err
 		}
 
-		s, err := tmpClient.Logical().Write(
+		s, err := loginClient.Logical().Write(
 			fmt.Sprintf(certAuthLoginPath, mount),
 			map[string]any{"name": role},
 		)
+
+		c.observeLogin("cert", err)
+
 		if err != nil {
 			return fmt.Errorf("error performing cert auth: %w", err)
 		}
@@ -223,9 +404,53 @@ func WithCertAuth(mount, role, certFile, keyFile, caFile string) Option {
 		c.SetToken(s.Auth.ClientToken)
 
 		if c.AuthMethodFunc == nil {
-			c.AuthMethodFunc = WithCertAuth(mount, role, certFile, keyFile, caFile)
+			c.AuthMethodFunc = withCertAuth(mount, role, keyPair, caFile)
 		}
 
 		return nil
 	}
 }
+
+// newCertAuthClient returns a Vault client presenting the current certificate of keyPair during the TLS handshake.
+// The TLS settings of base are used if set, otherwise caFile verifies Vault's certificate.
+func newCertAuthClient(address string, base *tls.Config, keyPair *certs.KeyPair, caFile string) (*api.Client, error) {
+	cfg := api.DefaultConfig()
+	cfg.Address = address
+
+	if base == nil && caFile != "" {
+		err := cfg.ConfigureTLS(&api.TLSConfig{CACert: caFile})
+		if err != nil {
+			return nil, fmt.Errorf("error configuring TLS for cert auth: %w", err)
+		}
+	}
+
+	transport, ok := cfg.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("error configuring TLS for cert auth: unexpected transport")
+	}
+
+	if base != nil {
+		transport.TLSClientConfig = base.Clone()
+	}
+
+	transport.TLSClientConfig.GetClientCertificate = keyPair.GetClientCertificate
+
+	// logins are rare, a new connection ensures a rotated certificate is presented
+	transport.DisableKeepAlives = true
+
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cert auth client: %w", err)
+	}
+
+	return client, nil
+}
+
+// observeLogin counts a login with the given auth method and reports failed logins.
+func (c *Client) observeLogin(method string, err error) {
+	metrics.VaultAuthTotal.WithLabelValues(method, metrics.Outcome(err)).Inc()
+
+	if err != nil {
+		c.events.Warning(events.ReasonAuthenticationFailed, fmt.Sprintf("%s login to vault failed: %s", method, err))
+	}
+}
@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	plaintextBodyPrefix = `{"plaintext":"`
+	plaintextBodySuffix = `"}`
+)
+
+// WithHardenedMemory keeps plaintext DEKs in byte slices, that are wiped after use, instead of strings,
+// which remain on the heap until they are garbage collected. Transit batching is not used in this mode.
+func WithHardenedMemory(enabled bool) Option {
+	return func(c *Client) error {
+		c.hardened = enabled
+
+		return nil
+	}
+}
+
+// writePlaintext sends the plaintext to a transit endpoint, encoding the request body without intermediate strings.
+func (c *Client) writePlaintext(ctx context.Context, path string, plaintext []byte) (*api.Secret, error) {
+	body := encodePlaintextBody(plaintext)
+	defer secure.Wipe(body)
+
+	resp, err := withTokenRetry(ctx, c, func() (*api.Response, error) {
+		return c.writeRaw(ctx, path, body)
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return api.ParseSecret(resp.Body)
+}
+
+// readPlaintext sends the input to a transit endpoint and decodes the plaintext of the response
+// without intermediate strings, the response body is wiped after decoding.
+func (c *Client) readPlaintext(ctx context.Context, path string, input map[string]any) ([]byte, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := withTokenRetry(ctx, c, func() (*api.Response, error) {
+		return c.writeRaw(ctx, path, body)
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	if err != nil {
+		return nil, wrapServerStateErr(err)
+	}
+
+	data, err := secure.ReadAll(resp.Body, resp.ContentLength)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, requestID, err := decodePlaintextBody(data)
+
+	audit.AddVaultRequestID(ctx, requestID)
+
+	return plaintext, err
+}
+
+// encodePlaintextBody returns the JSON body {"plaintext":"<base64>"} in a single buffer.
+func encodePlaintextBody(plaintext []byte) []byte {
+	n := base64.StdEncoding.EncodedLen(len(plaintext))
+
+	body := make([]byte, len(plaintextBodyPrefix)+n+len(plaintextBodySuffix))
+	copy(body, plaintextBodyPrefix)
+	base64.StdEncoding.Encode(body[len(plaintextBodyPrefix):], plaintext)
+	copy(body[len(plaintextBodyPrefix)+n:], plaintextBodySuffix)
+
+	return body
+}
+
+// decodePlaintextBody decodes the base64 plaintext and the request ID of a transit decrypt response and wipes the body.
+func decodePlaintextBody(body []byte) ([]byte, string, error) {
+	defer secure.Wipe(body)
+
+	// json decodes base64 strings directly into byte slices
+	var resp struct {
+		RequestID string `json:"request_id"`
+		Data      struct {
+			Plaintext []byte `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		return nil, "", errors.New("invalid response")
+	}
+
+	if resp.Data.Plaintext == nil {
+		return nil, resp.RequestID, errors.New("invalid response")
+	}
+
+	return resp.Data.Plaintext, resp.RequestID, nil
+}
+
+// writeRaw sends body to a transit endpoint. The response body of failed requests is closed,
+// so that they can be retried, see withTokenRetry.
+func (c *Client) writeRaw(ctx context.Context, path string, body []byte) (*api.Response, error) {
+	resp, err := c.logical().WriteRawWithContext(ctx, path, body)
+	if err != nil && resp != nil {
+		resp.Body.Close()
+
+		return nil, err
+	}
+
+	return resp, err
+}
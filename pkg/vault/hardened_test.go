@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/secure"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePlaintextBody(t *testing.T) {
+	for _, plaintext := range [][]byte{{}, []byte("a"), []byte("dek-0123456789abcdef")} {
+		body := encodePlaintextBody(plaintext)
+
+		var req struct {
+			Plaintext string `json:"plaintext"`
+		}
+
+		require.NoError(t, json.Unmarshal(body, &req))
+		require.Equal(t, base64.StdEncoding.EncodeToString(plaintext), req.Plaintext)
+	}
+}
+
+func TestDecodePlaintextBody(t *testing.T) {
+	body := []byte(`{"request_id":"1","data":{"plaintext":"` + base64.StdEncoding.EncodeToString([]byte("dek")) + `"}}`)
+
+	plaintext, requestID, err := decodePlaintextBody(body)
+	require.NoError(t, err)
+	require.Equal(t, []byte("dek"), plaintext)
+	require.Equal(t, "1", requestID)
+	require.True(t, secure.IsZero(body), "response body must be wiped")
+
+	body = []byte(`{"data":{}}`)
+
+	_, _, err = decodePlaintextBody(body)
+	require.Error(t, err)
+	require.True(t, secure.IsZero(body), "response body must be wiped")
+}
+
+func TestHardenedMemory(t *testing.T) {
+	fv := newFakeVault(t)
+
+	fv.Handle("/v1/transit/encrypt/kms", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Plaintext []byte `json:"plaintext"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || string(req.Plaintext) != "dek" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid plaintext"}})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"ciphertext": "vault:v1:dek", "key_version": 1}})
+	})
+
+	fv.Handle("/v1/transit/decrypt/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte("dek"))}})
+	})
+
+	fv.Handle("/v1/transit/keys/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"latest_version": 1}})
+	})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+		// batching is bypassed
+		WithBatching(time.Second, 10),
+		WithHardenedMemory(true),
+	)
+	require.NoError(t, err)
+
+	cipher, kv, err := vc.Encrypt(t.Context(), []byte("dek"))
+	require.NoError(t, err)
+	require.Equal(t, "vault:v1:dek", string(cipher))
+	require.Equal(t, "1", kv)
+
+	plaintext, err := vc.Decrypt(t.Context(), cipher)
+	require.NoError(t, err)
+	require.Equal(t, []byte("dek"), plaintext)
+
+	_, err = vc.ForTransitKey("transit", "missing", "").Decrypt(t.Context(), cipher)
+	require.Error(t, err)
+}
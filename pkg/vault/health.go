@@ -0,0 +1,217 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/events"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/hashicorp/vault/api"
+	"go.uber.org/zap"
+)
+
+const drSecondaryMode = "secondary"
+
+var (
+	// ErrVaultSealed is returned when the Vault server is sealed.
+	ErrVaultSealed = errors.New("vault sealed")
+
+	// ErrVaultNotInitialized is returned when the Vault server has not been initialized.
+	ErrVaultNotInitialized = errors.New("vault not initialized")
+
+	// ErrVaultDRSecondary is returned when the Vault server is a DR secondary, which does not serve client requests.
+	ErrVaultDRSecondary = errors.New("vault is a dr secondary")
+)
+
+// ServerState is the state of the Vault server as reported by sys/health.
+type ServerState struct {
+	Initialized        bool
+	Sealed             bool
+	Standby            bool
+	PerformanceStandby bool
+	DRSecondary        bool
+	Version            string
+}
+
+// Err returns an error if the Vault server is not able to serve transit requests.
+func (s *ServerState) Err() error {
+	switch {
+	case !s.Initialized:
+		return ErrVaultNotInitialized
+	case s.Sealed:
+		return ErrVaultSealed
+	case s.DRSecondary:
+		return ErrVaultDRSecondary
+	}
+
+	return nil
+}
+
+// String returns a short human readable representation of the state.
+func (s *ServerState) String() string {
+	switch {
+	case !s.Initialized:
+		return "uninitialized"
+	case s.Sealed:
+		return "sealed"
+	case s.DRSecondary:
+		return "dr_secondary"
+	case s.PerformanceStandby:
+		return "performance_standby"
+	case s.Standby:
+		return "standby"
+	}
+
+	return "active"
+}
+
+// ServerState returns the last known state of the Vault server or nil if it has not been checked yet.
+func (c *Client) ServerState() *ServerState {
+	return c.serverState.Load()
+}
+
+// CheckServerState reads sys/health and stores the resulting state of the Vault server.
+// https://developer.hashicorp.com/vault/api-docs/system/health
+func (c *Client) CheckServerState(ctx context.Context) (*ServerState, error) {
+	health, err := c.Sys().HealthWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ServerState{
+		Initialized:        health.Initialized,
+		Sealed:             health.Sealed,
+		Standby:            health.Standby,
+		PerformanceStandby: health.PerformanceStandby,
+		DRSecondary:        health.ReplicationDRMode == drSecondaryMode,
+		Version:            health.Version,
+	}
+
+	prev := c.serverState.Swap(state)
+	if prev == nil || prev.String() != state.String() {
+		logServerState(state)
+		c.reportServerState(prev, state)
+	}
+
+	setServerStateMetrics(state)
+
+	return state, nil
+}
+
+// ServerStateWatcher periodically checks the state of the Vault server.
+// this func is supposed to run as a goroutine.
+func (c *Client) ServerStateWatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, err := c.CheckServerState(ctx)
+			if err != nil {
+				logging.L(logging.ComponentVault).Error("failed to check vault server state", zap.Error(err))
+				c.events.Warning(events.ReasonVaultUnhealthy, fmt.Sprintf("failed to check vault server state: %s", err))
+			}
+		case <-ctx.Done():
+			logging.L(logging.ComponentVault).Info("vault server state watcher shutting down")
+
+			return
+		}
+	}
+}
+
+// Health returns an error if the last known state of the Vault server does not allow serving transit requests.
+func (c *Client) Health(ctx context.Context) error {
+	state := c.ServerState()
+	if state == nil {
+		var err error
+
+		state, err = c.CheckServerState(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return state.Err()
+}
+
+// serverStateErr returns an error if the Vault server is known to be unable to serve transit requests.
+func (c *Client) serverStateErr() error {
+	state := c.ServerState()
+	if state == nil {
+		return nil
+	}
+
+	return state.Err()
+}
+
+// wrapServerStateErr annotates errors caused by a sealed Vault server, so that callers can detect them using errors.Is.
+func wrapServerStateErr(err error) error {
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusServiceUnavailable {
+		return err
+	}
+
+	for _, e := range respErr.Errors {
+		if strings.Contains(strings.ToLower(e), "sealed") {
+			return fmt.Errorf("%w: %w", ErrVaultSealed, err)
+		}
+	}
+
+	return err
+}
+
+func logServerState(state *ServerState) {
+	fields := []zap.Field{
+		zap.String("state", state.String()),
+		zap.Bool("initialized", state.Initialized),
+		zap.Bool("sealed", state.Sealed),
+		zap.Bool("standby", state.Standby),
+		zap.Bool("performance_standby", state.PerformanceStandby),
+		zap.Bool("dr_secondary", state.DRSecondary),
+		zap.String("vault_version", state.Version),
+	}
+
+	if state.Err() != nil {
+		logging.L(logging.ComponentVault).Error("vault server is unable to serve requests", fields...)
+
+		return
+	}
+
+	logging.L(logging.ComponentVault).Info("vault server state changed", fields...)
+}
+
+// reportServerState reports the Vault server becoming unable or able again to serve transit requests.
+// Every transition is reported, so that a relapse after a recovery is not hidden by the rate limit of the events.
+func (c *Client) reportServerState(prev, state *ServerState) {
+	switch {
+	case state.Err() != nil && (prev == nil || prev.Err() == nil):
+		c.events.WarningTransition(events.ReasonVaultUnhealthy, fmt.Sprintf("vault server is %s and unable to serve requests", state))
+	case state.Err() == nil && prev != nil && prev.Err() != nil:
+		c.events.NormalTransition(events.ReasonVaultHealthy, fmt.Sprintf("vault server is %s again", state))
+	}
+}
+
+func setServerStateMetrics(state *ServerState) {
+	values := map[string]bool{
+		"initialized":         state.Initialized,
+		"sealed":              state.Sealed,
+		"standby":             state.Standby,
+		"performance_standby": state.PerformanceStandby,
+		"dr_secondary":        state.DRSecondary,
+	}
+
+	for label, v := range values {
+		gauge := metrics.VaultServerState.WithLabelValues(label)
+		if v {
+			gauge.Set(1)
+		} else {
+			gauge.Set(0)
+		}
+	}
+}
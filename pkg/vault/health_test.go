@@ -0,0 +1,136 @@
+package vault
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func handleHealth(fv *fakeVault, health map[string]any) {
+	fv.Handle("/v1/sys/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, 299, health)
+	})
+}
+
+func TestServerState(t *testing.T) {
+	testCases := []struct {
+		name   string
+		health map[string]any
+		state  string
+		err    error
+	}{
+		{
+			name:   "active",
+			health: map[string]any{"initialized": true},
+			state:  "active",
+		},
+		{
+			name:   "sealed",
+			health: map[string]any{"initialized": true, "sealed": true},
+			state:  "sealed",
+			err:    ErrVaultSealed,
+		},
+		{
+			name:   "not initialized",
+			health: map[string]any{"initialized": false, "sealed": true},
+			state:  "uninitialized",
+			err:    ErrVaultNotInitialized,
+		},
+		{
+			name:   "performance standby",
+			health: map[string]any{"initialized": true, "standby": true, "performance_standby": true},
+			state:  "performance_standby",
+		},
+		{
+			name:   "dr secondary",
+			health: map[string]any{"initialized": true, "standby": true, "replication_dr_mode": "secondary"},
+			state:  "dr_secondary",
+			err:    ErrVaultDRSecondary,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fv := newFakeVault(t)
+			handleHealth(fv, map[string]any{"initialized": true})
+
+			vc, err := NewClient(
+				WithVaultAddress(fv.URL),
+				WithTokenAuth("token"),
+				WithTransit("transit", "kms"),
+			)
+			require.NoError(t, err)
+
+			handleHealth(fv, tc.health)
+
+			state, err := vc.CheckServerState(t.Context())
+			require.NoError(t, err)
+			require.Equal(t, tc.state, state.String())
+			require.ErrorIs(t, state.Err(), tc.err)
+			require.ErrorIs(t, vc.Health(t.Context()), tc.err)
+		})
+	}
+}
+
+func TestNewClientReportsSealedVault(t *testing.T) {
+	fv := newFakeVault(t)
+	handleHealth(fv, map[string]any{"initialized": true, "sealed": true})
+
+	fv.Handle("/v1/auth/token/lookup-self", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"errors": []string{"Vault is sealed"}})
+	})
+
+	_, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+	)
+	require.ErrorIs(t, err, ErrVaultSealed)
+}
+
+func TestTransitFailsFastWhenSealed(t *testing.T) {
+	fv := newFakeVault(t)
+	handleHealth(fv, map[string]any{"initialized": true})
+
+	var encryptCalls atomic.Int32
+
+	fv.Handle("/v1/transit/encrypt/kms", func(w http.ResponseWriter, _ *http.Request) {
+		encryptCalls.Add(1)
+
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"errors": []string{"Vault is sealed"}})
+	})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+	)
+	require.NoError(t, err)
+
+	vc.SetMaxRetries(0)
+
+	// a 503 caused by a seal is annotated, even if the state has not been refreshed yet
+	_, _, err = vc.Encrypt(t.Context(), []byte("plain"))
+	require.ErrorIs(t, err, ErrVaultSealed)
+	require.EqualValues(t, 1, encryptCalls.Load())
+
+	handleHealth(fv, map[string]any{"initialized": true, "sealed": true})
+
+	go vc.ServerStateWatcher(t.Context(), 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		state := vc.ServerState()
+
+		return state != nil && state.Sealed
+	}, time.Second, 10*time.Millisecond)
+
+	// once the seal is known, requests are rejected without calling vault
+	_, _, err = vc.Encrypt(t.Context(), []byte("plain"))
+	require.ErrorIs(t, err, ErrVaultSealed)
+
+	_, err = vc.Decrypt(t.Context(), []byte("vault:v1:cipher"))
+	require.ErrorIs(t, err, ErrVaultSealed)
+	require.EqualValues(t, 1, encryptCalls.Load())
+}
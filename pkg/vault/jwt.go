@@ -101,6 +101,9 @@ func withJWTTokenSource(mount, role string, source jwtTokenSource) Option {
 			"role": role,
 			"jwt":  jwtToken,
 		})
+
+		c.observeLogin("jwt", err)
+
 		if err != nil {
 			return fmt.Errorf("error performing jwt auth: %w", err)
 		}
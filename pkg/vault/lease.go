@@ -2,11 +2,14 @@ package vault
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/events"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
 	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
 	"github.com/hashicorp/vault/api"
 	"go.uber.org/zap"
@@ -19,107 +22,298 @@ func isAuthError(err error) bool {
 		(respErr.StatusCode == http.StatusUnauthorized || respErr.StatusCode == http.StatusForbidden)
 }
 
-func authenticateAndVerify(c *Client) error {
-	err := c.AuthMethodFunc(c)
-	if err != nil {
-		return err
-	}
+const (
+	// loginMinBackoff and loginMaxBackoff bound the interval between failed logins.
+	loginMinBackoff = time.Second
+	loginMaxBackoff = time.Minute
+)
+
+// ErrTokenExpired is returned by the health check once the token expired without being replaced.
+var ErrTokenExpired = errors.New("vault token expired")
 
-	_, err = c.Auth().Token().LookupSelf()
+// TokenState is the state of the current Vault token.
+type TokenState struct {
+	// ExpireTime is the time the token expires, zero for tokens without TTL.
+	ExpireTime time.Time
+	Renewable  bool
 
-	return err
+	// Err is the error of the last failed verification or login, nil once the token is valid again.
+	Err error
 }
 
-// LeaseRefresher periodically checks the ttl of the current lease and attempts to renew it if the ttl is less than half of the creation ttl.
-// if the token renewal fails, a new login with the configured auth method is performed
-// this func is supposed to run as a goroutine.
-// nolint: funlen, gocognit, cyclop
-func (c *Client) LeaseRefresher(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// TokenManager keeps the token of a client valid. The token is renewed relative to its TTL by Vault's
+// LifetimeWatcher and replaced by a new login before it reaches its max TTL or expires without being renewable.
+// The token is verified every interval, so that revoked tokens are replaced as well. Tokens published by
+// re-authentications triggered by denied requests are picked up immediately, see Client.Reauthenticate.
+// Failed logins are retried with exponential backoff.
+type TokenManager struct {
+	client   *Client
+	interval time.Duration
 
-	for {
-		select {
-		case <-ticker.C:
-			token, err := c.Auth().Token().LookupSelf()
-			if err != nil {
-				if isAuthError(err) {
-					zap.L().Error("failed to lookup token, performing new authentication", zap.Error(err))
+	minBackoff time.Duration
+	maxBackoff time.Duration
 
-					authErr := authenticateAndVerify(c)
-					if authErr != nil {
-						zap.L().Error("failed to authenticate or verify token", zap.Error(authErr))
-					} else {
-						zap.L().Info("successfully re-authenticated")
-					}
+	state atomic.Pointer[TokenState]
+}
+
+// NewTokenManager returns a token manager verifying the token of c every interval.
+func NewTokenManager(c *Client, interval time.Duration) *TokenManager {
+	return &TokenManager{
+		client:     c,
+		interval:   interval,
+		minBackoff: loginMinBackoff,
+		maxBackoff: loginMaxBackoff,
+	}
+}
 
-					continue
-				}
+// Run manages the token until ctx is done.
+// this func is supposed to run as a goroutine.
+func (m *TokenManager) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		// subscribe before the lookup, so that no token published in between is missed
+		swapped := m.client.swapper.Swapped()
 
-				zap.L().Error("failed to lookup token", zap.Error(err))
+		secret, err := m.client.Auth().Token().LookupSelfWithContext(ctx)
 
+		switch {
+		case err == nil:
+			if !m.watch(ctx, secret, swapped) {
 				continue
 			}
+		case isAuthError(err):
+			logging.L(logging.ComponentVault).Error("vault token is invalid, performing new authentication", zap.Error(err))
+			m.setErr(err)
+		default:
+			logging.L(logging.ComponentVault).Error("failed to lookup token", zap.Error(err))
+			m.setErr(err)
 
-			creationTTL, ok := token.Data["creation_ttl"].(json.Number)
-			if !ok {
-				zap.L().Error("failed to assert creation_ttl type")
+			sleep(ctx, m.interval)
 
-				continue
-			}
+			continue
+		}
 
-			ttl, ok := token.Data["ttl"].(json.Number)
-			if !ok {
-				zap.L().Error("failed to assert ttl type")
+		if ctx.Err() != nil {
+			break
+		}
 
-				continue
-			}
+		m.login(ctx)
+	}
+
+	logging.L(logging.ComponentVault).Info("token manager shutting down")
+}
+
+// State returns the state of the current token, nil if it has not been looked up yet.
+func (m *TokenManager) State() *TokenState {
+	return m.state.Load()
+}
 
-			creationTTLFloat, err := creationTTL.Float64()
+// Health returns an error once the token expired without being replaced.
+func (m *TokenManager) Health(_ context.Context) error {
+	state := m.State()
+	if state == nil || state.ExpireTime.IsZero() || time.Now().Before(state.ExpireTime) {
+		return nil
+	}
+
+	if state.Err != nil {
+		return fmt.Errorf("%w at %s: %w", ErrTokenExpired, state.ExpireTime.Format(time.RFC3339), state.Err)
+	}
+
+	return fmt.Errorf("%w at %s", ErrTokenExpired, state.ExpireTime.Format(time.RFC3339))
+}
+
+// watch renews the token until it has to be replaced by a new login, another token was published or ctx is done.
+// It returns whether a new login is required.
+// nolint: cyclop
+func (m *TokenManager) watch(ctx context.Context, secret *api.Secret, swapped <-chan struct{}) bool {
+	ttl, _ := secret.TokenTTL()
+	renewable, _ := secret.TokenIsRenewable()
+
+	m.setState(ttl, renewable)
+
+	logging.L(logging.ComponentVault).Info("managing token", zap.Duration("ttl", ttl), zap.Bool("renewable", renewable))
+
+	var (
+		renewCh <-chan *api.RenewOutput
+		doneCh  <-chan error
+	)
+
+	// tokens without TTL, such as root tokens, never expire
+	if ttl > 0 {
+		watcher, err := m.client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+			Secret: &api.Secret{Auth: &api.SecretAuth{
+				ClientToken:   m.client.Client.Token(),
+				Renewable:     renewable,
+				LeaseDuration: int(ttl.Seconds()),
+			}},
+			Increment: m.client.TokenRenewalSeconds,
+		})
+		if err != nil {
+			logging.L(logging.ComponentVault).Error("failed to watch token", zap.Error(err))
+
+			return true
+		}
+
+		go watcher.Start()
+		defer watcher.Stop()
+
+		renewCh, doneCh = watcher.RenewCh(), watcher.DoneCh()
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case renewal := <-renewCh:
+			ttl := time.Duration(renewal.Secret.Auth.LeaseDuration) * time.Second
+
+			m.setState(ttl, renewal.Secret.Auth.Renewable)
+			metrics.VaultTokenRenewalTotal.Inc()
+
+			logging.L(logging.ComponentVault).Info("successfully renewed token", zap.Duration("ttl", ttl))
+		case err := <-doneCh:
 			if err != nil {
-				zap.L().Error("failed to parse creation_ttl", zap.Error(err))
+				logging.L(logging.ComponentVault).Error("failed to renew token, performing new authentication", zap.Error(err))
+			} else {
+				logging.L(logging.ComponentVault).Info("token is about to reach its max ttl or is not renewable, performing new authentication",
+					zap.Time("expire_time", m.State().ExpireTime))
+			}
 
-				continue
+			return true
+		case <-swapped:
+			logging.L(logging.ComponentVault).Info("token was replaced by a re-authentication")
+
+			return false
+		case <-ticker.C:
+			_, err := m.client.Auth().Token().LookupSelfWithContext(ctx)
+			if isAuthError(err) {
+				logging.L(logging.ComponentVault).Error("vault token is invalid, performing new authentication", zap.Error(err))
+				m.setErr(err)
+
+				return true
 			}
 
-			ttlFloat, err := ttl.Float64()
 			if err != nil {
-				zap.L().Error("failed to parse ttl", zap.Error(err))
+				logging.L(logging.ComponentVault).Warn("failed to verify token", zap.Error(err))
 
 				continue
 			}
 
-			metrics.VaultTokenExpirySeconds.Set(ttlFloat)
+			if expireTime := m.State().ExpireTime; !expireTime.IsZero() {
+				metrics.VaultTokenExpirySeconds.Set(time.Until(expireTime).Seconds())
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
 
-			zap.L().Info("checking token renewal", zap.Float64("creation_ttl", creationTTLFloat), zap.Float64("ttl", ttlFloat))
+// login authenticates with exponential backoff until it succeeds or ctx is done.
+func (m *TokenManager) login(ctx context.Context) {
+	backoff := m.minBackoff
 
-			//nolint: nestif
-			if ttlFloat < creationTTLFloat/2 {
-				zap.L().Info("attempting token renewal", zap.Int("renewal_seconds", c.TokenRenewalSeconds))
+	for attempt := 1; ctx.Err() == nil; attempt++ {
+		err := m.client.Reauthenticate(ctx)
+		if err == nil {
+			logging.L(logging.ComponentVault).Info("successfully re-authenticated", zap.Int("attempt", attempt))
 
-				_, err = c.Auth().Token().RenewSelf(c.TokenRenewalSeconds)
-				if err != nil {
-					zap.L().Error("failed to renew token, performing new authentication", zap.Error(err))
+			return
+		}
 
-					err = c.AuthMethodFunc(c)
-					if err != nil {
-						zap.L().Error("failed to authenticate", zap.Error(err))
-					} else {
-						zap.L().Info("successfully re-authenticated")
-					}
-				} else {
-					zap.L().Info("successfully refreshed token")
-				}
+		if ctx.Err() != nil {
+			return
+		}
 
-				metrics.VaultTokenRenewalTotal.Inc()
-			} else {
-				zap.L().Info("skipping token renewal")
-			}
+		m.setErr(err)
 
-		case <-ctx.Done():
-			zap.L().Info("token refresher shutting down")
+		logging.L(logging.ComponentVault).Error("failed to authenticate or verify token, retrying",
+			zap.Error(err), zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
 
-			return
+		m.client.events.Warning(events.ReasonAuthenticationFailed, fmt.Sprintf("failed to authenticate to vault or verify the token: %s", err))
+
+		if state := m.State(); state != nil && !state.ExpireTime.IsZero() {
+			m.client.events.Warning(events.ReasonTokenExpiring,
+				fmt.Sprintf("failed to re-authenticate, the vault token expires in %s", time.Until(state.ExpireTime).Round(time.Second)))
 		}
+
+		sleep(ctx, backoff)
+
+		backoff = min(2*backoff, m.maxBackoff)
 	}
 }
+
+func (m *TokenManager) setState(ttl time.Duration, renewable bool) {
+	state := &TokenState{Renewable: renewable}
+
+	if ttl > 0 {
+		state.ExpireTime = time.Now().Add(ttl)
+	}
+
+	m.state.Store(state)
+
+	metrics.VaultTokenExpirySeconds.Set(ttl.Seconds())
+}
+
+func (m *TokenManager) setErr(err error) {
+	state := TokenState{}
+
+	if prev := m.State(); prev != nil {
+		state = *prev
+	}
+
+	state.Err = err
+
+	m.state.Store(&state)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// TokenInfo is the metadata of the current token, it never contains the token itself.
+type TokenInfo struct {
+	Accessor    string    `json:"accessor"`
+	DisplayName string    `json:"display_name"`
+	Policies    []string  `json:"policies"`
+	TTL         string    `json:"ttl"`
+	ExpireTime  time.Time `json:"expire_time,omitzero"`
+	Renewable   bool      `json:"renewable"`
+}
+
+// TokenInfo looks up the metadata of the current token.
+func (c *Client) TokenInfo(ctx context.Context) (*TokenInfo, error) {
+	secret, err := c.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("empty token lookup response from vault")
+	}
+
+	info := &TokenInfo{}
+
+	info.Accessor, _ = secret.TokenAccessor()
+	info.Policies, _ = secret.TokenPolicies()
+	info.Renewable, _ = secret.TokenIsRenewable()
+
+	ttl, _ := secret.TokenTTL()
+	info.TTL = ttl.String()
+
+	if name, ok := secret.Data["display_name"].(string); ok {
+		info.DisplayName = name
+	}
+
+	if expire, ok := secret.Data["expire_time"].(string); ok {
+		info.ExpireTime, _ = time.Parse(time.RFC3339Nano, expire)
+	}
+
+	return info, nil
+}
@@ -2,8 +2,14 @@ package vault
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"strings"
+	"sync/atomic"
+	"testing"
 	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func (s *VaultSuite) TestTokenRefresher() {
@@ -23,7 +29,7 @@ func (s *VaultSuite) TestTokenRefresher() {
 
 		s.Require().NoError(err, "client")
 
-		go vc.LeaseRefresher(ctx, 3*time.Second)
+		go NewTokenManager(vc, 3*time.Second).Run(ctx)
 
 		time.Sleep(15 * time.Second)
 
@@ -73,7 +79,7 @@ func (s *VaultSuite) TestTokenRefresherReauthenticatesOnLookupFailure() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		go vc.LeaseRefresher(ctx, 500*time.Millisecond)
+		go NewTokenManager(vc, 500*time.Millisecond).Run(ctx)
 
 		s.Eventually(func() bool {
 			_, err := vc.Auth().Token().LookupSelf()
@@ -82,3 +88,137 @@ func (s *VaultSuite) TestTokenRefresherReauthenticatesOnLookupFailure() {
 		}, 8*time.Second, 250*time.Millisecond)
 	})
 }
+
+// handleLogin serves an approle login, failing the first failures attempts.
+func handleLogin(fv *fakeVault, token string, failures int32) *atomic.Int32 {
+	var attempts atomic.Int32
+
+	fv.Handle("/v1/auth/approle/login", func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) <= failures {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret id"}})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": token, "renewable": true, "lease_duration": 3600}})
+	})
+
+	return &attempts
+}
+
+// handleLookupSelf serves the lookup of tokens by their ttl, unknown tokens are invalid.
+func handleLookupSelf(fv *fakeVault, ttls map[string]map[string]any) {
+	fv.Handle("/v1/auth/token/lookup-self", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := ttls[r.Header.Get("X-Vault-Token")]
+		if !ok {
+			writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": data})
+	})
+}
+
+func TestTokenManagerRenewsToken(t *testing.T) {
+	fv := newFakeVault(t)
+	handleLookupSelf(fv, map[string]map[string]any{"initial": {"ttl": 3, "renewable": true}})
+
+	var renewals atomic.Int32
+
+	fv.Handle("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "initial", r.Header.Get("X-Vault-Token"))
+
+		renewals.Add(1)
+
+		writeJSON(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": "initial", "renewable": true, "lease_duration": 3600}})
+	})
+
+	vc, err := NewClient(WithVaultAddress(fv.URL), WithTokenAuth("initial"))
+	require.NoError(t, err)
+
+	m := NewTokenManager(vc, time.Hour)
+
+	go m.Run(t.Context())
+
+	// renewed by the lifetime watcher instead of after the interval
+	require.Eventually(t, func() bool {
+		state := m.State()
+
+		return renewals.Load() == 1 && state != nil && time.Until(state.ExpireTime) > time.Minute
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, m.Health(t.Context()))
+}
+
+func TestTokenManagerReauthenticatesBeforeExpiry(t *testing.T) {
+	fv := newFakeVault(t)
+	handleLookupSelf(fv, map[string]map[string]any{
+		"initial": {"ttl": 2, "renewable": false},
+		"new":     {"ttl": 3600, "renewable": true},
+	})
+	handleLogin(fv, "initial", 0)
+
+	vc, err := NewClient(WithVaultAddress(fv.URL), WithAppRoleAuth("approle", "kms", "secret"))
+	require.NoError(t, err)
+	require.Equal(t, "initial", vc.Client.Token())
+
+	handleLogin(fv, "new", 0)
+
+	m := NewTokenManager(vc, time.Hour)
+
+	go m.Run(t.Context())
+
+	// the non-renewable token is replaced before it expires
+	require.Eventually(t, func() bool {
+		return vc.Client.Token() == "new"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		state := m.State()
+
+		return state != nil && state.Renewable && state.Err == nil
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestTokenManagerRetriesLogin(t *testing.T) {
+	fv := newFakeVault(t)
+	handleLookupSelf(fv, map[string]map[string]any{"initial": {"ttl": 3600, "renewable": true}})
+	handleLogin(fv, "initial", 0)
+
+	vc, err := NewClient(WithVaultAddress(fv.URL), WithAppRoleAuth("approle", "kms", "secret"))
+	require.NoError(t, err)
+
+	// the token is revoked and the next two logins fail
+	handleLookupSelf(fv, map[string]map[string]any{"new": {"ttl": 3600, "renewable": true}})
+	attempts := handleLogin(fv, "new", 2)
+
+	m := NewTokenManager(vc, 10*time.Millisecond)
+	m.minBackoff = 10 * time.Millisecond
+
+	go m.Run(t.Context())
+
+	require.Eventually(t, func() bool {
+		return vc.Client.Token() == "new"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	require.Equal(t, int32(3), attempts.Load())
+}
+
+func TestTokenManagerHealth(t *testing.T) {
+	m := NewTokenManager(nil, time.Hour)
+
+	require.NoError(t, m.Health(t.Context()), "not looked up yet")
+
+	m.state.Store(&TokenState{})
+	require.NoError(t, m.Health(t.Context()), "token without ttl")
+
+	m.state.Store(&TokenState{ExpireTime: time.Now().Add(time.Minute), Err: errors.New("login failed")})
+	require.NoError(t, m.Health(t.Context()), "token still valid")
+
+	m.state.Store(&TokenState{ExpireTime: time.Now().Add(-time.Minute), Err: errors.New("login failed")})
+
+	err := m.Health(t.Context())
+	require.ErrorIs(t, err, ErrTokenExpired)
+	require.ErrorContains(t, err, "login failed")
+}
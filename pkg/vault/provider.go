@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"sync/atomic"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// ForTransitKey returns a client for another transit key, e.g. of another KMS provider.
+// The returned client shares the authentication, token renewal, re-authentication, server state, rate limit and events with c,
+// but uses its own transit mount, key and namespace. An empty namespace uses the namespace of c.
+func (c *Client) ForTransitKey(mount, key, namespace string) *Client {
+	p := &Client{
+		Client:              c.Client,
+		AuthMethodFunc:      c.AuthMethodFunc,
+		TokenRenewalSeconds: c.TokenRenewalSeconds,
+		TransitEngine:       mount,
+		TransitKey:          key,
+		namespace:           namespace,
+		batchWindow:         c.batchWindow,
+		batchMaxSize:        c.batchMaxSize,
+		serverState:         c.serverState,
+		transport:           c.transport,
+		hardened:            c.hardened,
+		tlsConfig:           c.tlsConfig,
+		events:              c.events,
+		keyVersion:          &atomic.Int64{},
+		swapper:             c.swapper,
+	}
+
+	if p.batchWindow > 0 {
+		p.encryptBatcher = newBatcher("encrypt", p.batchWindow, p.batchMaxSize, c.ClientTimeout(), p.encryptBatch)
+		p.decryptBatcher = newBatcher("decrypt", p.batchWindow, p.batchMaxSize, c.ClientTimeout(), p.decryptBatch)
+	}
+
+	return p
+}
+
+// logical returns the logical backend scoped to the namespace of the client.
+func (c *Client) logical() *api.Logical {
+	if c.namespace == "" {
+		return c.Logical()
+	}
+
+	return c.WithNamespace(c.namespace).Logical()
+}
@@ -0,0 +1,82 @@
+package vault
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForTransitKey(t *testing.T) {
+	fv := newFakeVault(t)
+
+	type request struct {
+		namespace string
+		token     string
+	}
+
+	requests := map[string]request{}
+
+	for _, key := range []string{"kms", "secrets"} {
+		fv.Handle("/v1/transit/encrypt/"+key, func(w http.ResponseWriter, r *http.Request) {
+			requests[key] = request{
+				namespace: r.Header.Get("X-Vault-Namespace"),
+				token:     r.Header.Get("X-Vault-Token"),
+			}
+
+			writeJSON(w, http.StatusOK, map[string]any{
+				"data": map[string]any{"ciphertext": "vault:v1:" + key},
+			})
+		})
+
+		fv.Handle("/v1/transit/keys/"+key, func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"latest_version": 1}})
+		})
+	}
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+	)
+	require.NoError(t, err)
+
+	provider := vc.ForTransitKey("transit", "secrets", "team-a")
+
+	// a token obtained by the shared client after a re-authentication is used by all providers
+	vc.SetToken("new-token")
+
+	cipher, kv, err := provider.Encrypt(t.Context(), []byte("plain"))
+	require.NoError(t, err)
+	require.Equal(t, "vault:v1:secrets", string(cipher))
+	require.Equal(t, "1", kv)
+	require.Equal(t, request{namespace: "team-a", token: "new-token"}, requests["secrets"])
+
+	// the original client is not affected
+	cipher, _, err = vc.Encrypt(t.Context(), []byte("plain"))
+	require.NoError(t, err)
+	require.Equal(t, "vault:v1:kms", string(cipher))
+	require.Equal(t, request{token: "new-token"}, requests["kms"])
+}
+
+func TestForTransitKeySharesServerState(t *testing.T) {
+	fv := newFakeVault(t)
+	handleHealth(fv, map[string]any{"initialized": true})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+	)
+	require.NoError(t, err)
+
+	provider := vc.ForTransitKey("transit", "secrets", "")
+
+	handleHealth(fv, map[string]any{"initialized": true, "sealed": true})
+
+	_, err = vc.CheckServerState(t.Context())
+	require.NoError(t, err)
+
+	_, _, err = provider.Encrypt(t.Context(), []byte("plain"))
+	require.ErrorIs(t, err, ErrVaultSealed)
+}
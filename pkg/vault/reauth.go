@@ -0,0 +1,170 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// deniedReauthMinInterval is the minimum interval between re-authentications triggered by denied requests,
+// so that requests denied by the policy of a valid token do not cause a login each.
+const deniedReauthMinInterval = 10 * time.Second
+
+// tokenSwapper serializes the re-authentications of the clients sharing a token, see ForTransitKey.
+type tokenSwapper struct {
+	mu sync.Mutex
+
+	// running is the running re-authentication, nil if none is running.
+	running *reauthentication
+
+	// lastDenied is the time of the last re-authentication triggered by a denied request.
+	lastDenied time.Time
+
+	// swapped is closed and replaced once a new token is published.
+	swapped chan struct{}
+}
+
+// reauthentication is a single login shared by all callers waiting for it.
+type reauthentication struct {
+	done chan struct{}
+	err  error
+}
+
+func newTokenSwapper() *tokenSwapper {
+	return &tokenSwapper{swapped: make(chan struct{})}
+}
+
+// start starts login unless a re-authentication is already running, which is returned instead.
+// If throttle is set, no new re-authentication is started within deniedReauthMinInterval and nil is returned.
+func (s *tokenSwapper) start(login func() error, throttle bool) *reauthentication {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running != nil {
+		return s.running
+	}
+
+	if throttle {
+		if time.Since(s.lastDenied) < deniedReauthMinInterval {
+			return nil
+		}
+
+		s.lastDenied = time.Now()
+	}
+
+	r := &reauthentication{done: make(chan struct{})}
+	s.running = r
+
+	// the login is not bound to the context of a single caller, since all callers wait for it
+	go func() {
+		r.err = login()
+
+		s.mu.Lock()
+		s.running = nil
+
+		if r.err == nil {
+			close(s.swapped)
+			s.swapped = make(chan struct{})
+		}
+
+		s.mu.Unlock()
+
+		close(r.done)
+	}()
+
+	return r
+}
+
+// Swapped returns a channel, that is closed once a new token is published.
+func (s *tokenSwapper) Swapped() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.swapped
+}
+
+func (r *reauthentication) wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reauthenticate performs a new login and publishes the new token once it is verified.
+// Concurrent calls share a single login. Requests in flight keep using the previous token until the new one
+// is published, a failed login leaves the previous token in place.
+func (c *Client) Reauthenticate(ctx context.Context) error {
+	return c.swapper.start(c.reauthenticate, false).wait(ctx)
+}
+
+// reauthenticate logs in with a clone of the client, so that the token of the client is only replaced
+// once the new token is verified.
+func (c *Client) reauthenticate() error {
+	if c.AuthMethodFunc == nil {
+		return errors.New("no auth method configured")
+	}
+
+	apiClient, err := c.CloneWithHeaders()
+	if err != nil {
+		return err
+	}
+
+	// api.NewClient reads VAULT_TOKEN, logins must not send a token
+	apiClient.ClearToken()
+
+	login := *c
+	login.Client = apiClient
+
+	err = c.AuthMethodFunc(&login)
+	if err != nil {
+		return err
+	}
+
+	_, err = apiClient.Auth().Token().LookupSelf()
+	if err != nil {
+		return fmt.Errorf("failed to verify new token: %w", err)
+	}
+
+	c.SetToken(apiClient.Token())
+
+	return nil
+}
+
+// withTokenRetry performs the request and, if Vault denied it because the token became invalid, retries it once
+// with a new token. The new token is obtained by an immediate re-authentication, unless it was already replaced
+// while the request was in flight.
+func withTokenRetry[T any](ctx context.Context, c *Client, request func() (T, error)) (T, error) {
+	token := c.Client.Token()
+
+	res, err := request()
+	if !isAuthError(err) || c.swapper == nil {
+		return res, err
+	}
+
+	if c.Client.Token() == token {
+		if r := c.swapper.start(c.reauthenticate, true); r != nil {
+			reauthErr := r.wait(ctx)
+			if reauthErr != nil {
+				logging.L(logging.ComponentVault).Error("failed to re-authenticate after denied request", zap.Error(reauthErr))
+
+				return res, err
+			}
+		}
+
+		// the denied request was not caused by an outdated token
+		if c.Client.Token() == token {
+			return res, err
+		}
+	}
+
+	logging.L(logging.ComponentVault).Info("request denied, retrying with new token", zap.Error(err))
+
+	return request()
+}
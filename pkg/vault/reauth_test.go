@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// handleDecrypt serves decrypt requests authorized by the valid token and returns the number of requests.
+func handleDecrypt(fv *fakeVault, valid *atomic.Value) *atomic.Int32 {
+	var requests atomic.Int32
+
+	fv.Handle("/v1/transit/decrypt/kms", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.Header.Get("X-Vault-Token") != valid.Load() {
+			writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte("dek"))}})
+	})
+
+	return &requests
+}
+
+func newReauthClient(t *testing.T, fv *fakeVault) *Client {
+	t.Helper()
+
+	handleLookupSelf(fv, map[string]map[string]any{"initial": {"ttl": 3600, "renewable": true}})
+	handleLogin(fv, "initial", 0)
+
+	vc, err := NewClient(WithVaultAddress(fv.URL), WithAppRoleAuth("approle", "kms", "secret"), WithTransit("transit", "kms"))
+	require.NoError(t, err)
+
+	return vc
+}
+
+func TestDeniedRequestsRetryWithNewToken(t *testing.T) {
+	fv := newFakeVault(t)
+	vc := newReauthClient(t, fv)
+
+	// the token is revoked
+	var valid atomic.Value
+	valid.Store("new")
+
+	handleDecrypt(fv, &valid)
+	handleLookupSelf(fv, map[string]map[string]any{"new": {"ttl": 3600, "renewable": true}})
+	attempts := handleLogin(fv, "new", 0)
+
+	var wg sync.WaitGroup
+
+	for range 10 {
+		wg.Go(func() {
+			plaintext, err := vc.Decrypt(t.Context(), []byte("vault:v1:dek"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("dek"), plaintext)
+		})
+	}
+
+	wg.Wait()
+
+	require.Equal(t, "new", vc.Client.Token())
+	require.Equal(t, int32(1), attempts.Load(), "concurrent denied requests share a single login")
+
+	// clients of other keys share the new token
+	_, err := vc.ForTransitKey("transit", "kms", "").Decrypt(t.Context(), []byte("vault:v1:dek"))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), attempts.Load())
+}
+
+func TestFailedReauthenticationKeepsToken(t *testing.T) {
+	fv := newFakeVault(t)
+	vc := newReauthClient(t, fv)
+
+	// the new token cannot be verified
+	attempts := handleLogin(fv, "unverified", 0)
+
+	err := vc.Reauthenticate(t.Context())
+	require.ErrorContains(t, err, "failed to verify new token")
+	require.Equal(t, int32(1), attempts.Load())
+	require.Equal(t, "initial", vc.Client.Token())
+}
+
+func TestDeniedRequestWithValidTokenIsNotRetried(t *testing.T) {
+	fv := newFakeVault(t)
+	vc := newReauthClient(t, fv)
+
+	// the token is valid, but the policy denies the request
+	var valid atomic.Value
+	valid.Store("other")
+
+	requests := handleDecrypt(fv, &valid)
+	attempts := handleLogin(fv, "initial", 0)
+
+	for range 2 {
+		_, err := vc.Decrypt(t.Context(), []byte("vault:v1:dek"))
+		require.ErrorContains(t, err, "permission denied")
+	}
+
+	require.Equal(t, int32(2), requests.Load(), "requests are not retried with the same token")
+	require.Equal(t, int32(1), attempts.Load(), "re-authentications of denied requests are throttled")
+}
+
+func TestTokenManagerPicksUpSwappedToken(t *testing.T) {
+	fv := newFakeVault(t)
+	vc := newReauthClient(t, fv)
+
+	ttls := map[string]int{"initial": 3600, "new": 7200}
+
+	handleLookupSelf(fv, map[string]map[string]any{
+		"initial": {"ttl": ttls["initial"], "renewable": true},
+		"new":     {"ttl": ttls["new"], "renewable": true},
+	})
+	attempts := handleLogin(fv, "new", 0)
+
+	fv.Handle("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Vault-Token")
+
+		writeJSON(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": token, "renewable": true, "lease_duration": ttls[token]}})
+	})
+
+	m := NewTokenManager(vc, time.Hour)
+
+	go m.Run(t.Context())
+
+	require.Eventually(t, func() bool {
+		return m.State() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, vc.Reauthenticate(t.Context()))
+
+	// the new token is looked up without waiting for the interval
+	require.Eventually(t, func() bool {
+		return time.Until(m.State().ExpireTime) > 90*time.Minute
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, int32(1), attempts.Load(), "the token manager performs no login of its own")
+}
@@ -6,29 +6,85 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/events"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	"github.com/hashicorp/vault/api"
 )
 
+// ErrTransitKeyNotFound is returned when the configured transit key cannot be read.
+var ErrTransitKeyNotFound = errors.New("transit key not found")
+
 // Encrypt takes any data and encrypts it using the specified vaults transit engine.
 func (c *Client) Encrypt(ctx context.Context, data []byte) ([]byte, string, error) {
-	p := fmt.Sprintf(encryptDataPath, c.TransitEngine, c.TransitKey)
-
-	opts := map[string]any{
-		"plaintext": base64.StdEncoding.EncodeToString(data),
-	}
-
-	resp, err := c.Logical().WriteWithContext(ctx, p, opts)
+	err := c.serverStateErr()
 	if err != nil {
 		return nil, "", err
 	}
 
-	res, ok := resp.Data["ciphertext"].(string)
-	if !ok {
-		return nil, "", errors.New("invalid response")
+	p := fmt.Sprintf(encryptDataPath, c.TransitEngine, c.TransitKey)
+
+	var res, kv string
+
+	switch {
+	case c.hardened:
+		resp, err := c.writePlaintext(ctx, p, data)
+		if err != nil {
+			return nil, "", wrapServerStateErr(err)
+		}
+
+		audit.AddVaultRequestID(ctx, resp.RequestID)
+
+		var ok bool
+
+		res, ok = resp.Data["ciphertext"].(string)
+		if !ok {
+			return nil, "", errors.New("invalid response")
+		}
+	case c.encryptBatcher != nil:
+		batchRes := c.encryptBatcher.submit(ctx, map[string]any{
+			"plaintext": base64.StdEncoding.EncodeToString(data),
+		})
+
+		audit.AddVaultRequestID(ctx, batchRes.requestID)
+
+		if batchRes.err != nil {
+			return nil, "", batchRes.err
+		}
+
+		// batch results already carry the key version used for encryption
+		res, kv = batchRes.value, batchRes.keyVersion
+
+		c.observeKeyVersion(ctx, kv)
+	default:
+		input := map[string]any{
+			"plaintext": base64.StdEncoding.EncodeToString(data),
+		}
+
+		resp, err := withTokenRetry(ctx, c, func() (*api.Secret, error) {
+			return c.logical().WriteWithContext(ctx, p, input)
+		})
+		if err != nil {
+			return nil, "", wrapServerStateErr(err)
+		}
+
+		audit.AddVaultRequestID(ctx, resp.RequestID)
+
+		var ok bool
+
+		res, ok = resp.Data["ciphertext"].(string)
+		if !ok {
+			return nil, "", errors.New("invalid response")
+		}
 	}
 
-	kv, err := c.GetKeyVersion(ctx)
-	if err != nil {
-		return nil, "", err
+	if kv == "" {
+		kv, err = c.GetKeyVersion(ctx)
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
 	return []byte(res), kv, nil
@@ -36,20 +92,49 @@ func (c *Client) Encrypt(ctx context.Context, data []byte) ([]byte, string, erro
 
 // Decrypt takes any encrypted data and decrypts it using the specified vaults transit engine.
 func (c *Client) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	err := c.serverStateErr()
+	if err != nil {
+		return nil, err
+	}
+
 	p := fmt.Sprintf(decryptDataPath, c.TransitEngine, c.TransitKey)
 
 	opts := map[string]any{
 		"ciphertext": string(data),
 	}
 
-	resp, err := c.Logical().WriteWithContext(ctx, p, opts)
-	if err != nil {
-		return nil, err
+	if c.hardened {
+		return c.readPlaintext(ctx, p, opts)
 	}
 
-	res, ok := resp.Data["plaintext"].(string)
-	if !ok {
-		return nil, errors.New("invalid response")
+	var res string
+
+	if c.decryptBatcher != nil {
+		batchRes := c.decryptBatcher.submit(ctx, opts)
+
+		audit.AddVaultRequestID(ctx, batchRes.requestID)
+
+		if batchRes.err != nil {
+			return nil, batchRes.err
+		}
+
+		res = batchRes.value
+	} else {
+		resp, err := withTokenRetry(ctx, c, func() (*api.Secret, error) {
+			return c.logical().WriteWithContext(ctx, p, opts)
+		})
+		if err != nil {
+			return nil, wrapServerStateErr(err)
+		}
+
+		audit.AddVaultRequestID(ctx, resp.RequestID)
+
+		var ok bool
+
+		res, ok = resp.Data["plaintext"].(string)
+		if !ok {
+			return nil, errors.New("invalid response")
+		}
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(res)
@@ -63,21 +148,51 @@ func (c *Client) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
 // GetKeyVersion returns the latest key version for the configured transit key.
 // https://developer.hashicorp.com/vault/api-docs/secret/transit#read-key
 func (c *Client) GetKeyVersion(ctx context.Context) (string, error) {
+	err := c.serverStateErr()
+	if err != nil {
+		return "", err
+	}
+
 	p := fmt.Sprintf(transitKeyPath, c.TransitEngine, c.TransitKey)
 
-	resp, err := c.Logical().ReadWithContext(ctx, p)
+	resp, err := withTokenRetry(ctx, c, func() (*api.Secret, error) {
+		return c.logical().ReadWithContext(ctx, p)
+	})
 	if err != nil {
-		return "", err
+		return "", wrapServerStateErr(err)
 	}
 
 	if resp == nil {
-		return "", fmt.Errorf("could not read transit key: %s/%s. Check transit engine and key and permissions", c.TransitEngine, c.TransitKey)
+		return "", fmt.Errorf("could not read transit key: %s/%s. Check transit engine and key and permissions: %w", c.TransitEngine, c.TransitKey, ErrTransitKeyNotFound)
 	}
 
+	audit.AddVaultRequestID(ctx, resp.RequestID)
+
 	kv, ok := resp.Data["latest_version"].(json.Number)
 	if !ok {
 		return "", fmt.Errorf("could not get latest_version of transit key: %s/%s", c.TransitEngine, c.TransitKey)
 	}
 
+	c.observeKeyVersion(ctx, kv.String())
+
 	return kv.String(), nil
 }
+
+// observeKeyVersion exposes the version of the transit key and reports its rotation, versions that are not numeric are ignored.
+func (c *Client) observeKeyVersion(ctx context.Context, kv string) {
+	version, err := strconv.Atoi(kv)
+	if err != nil {
+		return
+	}
+
+	metrics.TransitKeyVersion.WithLabelValues(metrics.Provider(ctx), c.TransitEngine, c.TransitKey).Set(float64(version))
+
+	if c.keyVersion == nil {
+		return
+	}
+
+	if prev := c.keyVersion.Swap(int64(version)); prev != 0 && prev != int64(version) {
+		c.events.Normal(events.ReasonTransitKeyRotated,
+			fmt.Sprintf("transit key %s/%s rotated from version %d to %d", c.TransitEngine, c.TransitKey, prev, version))
+	}
+}
@@ -2,6 +2,14 @@ package vault
 
 import (
 	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/audit"
+	"github.com/FalcoSuessgott/vault-kubernetes-kms/pkg/metrics"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
 )
 
 func (s *VaultSuite) TestTransitEncryptDecrypt() {
@@ -72,3 +80,63 @@ func (s *VaultSuite) TestTransitKeyVersion() {
 		})
 	}
 }
+
+func TestAuditVaultRequestIDs(t *testing.T) {
+	fv := newFakeVault(t)
+
+	fv.Handle("/v1/transit/encrypt/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"request_id": "encrypt", "data": map[string]any{"ciphertext": "vault:v1:dek"}})
+	})
+
+	fv.Handle("/v1/transit/decrypt/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"request_id": "decrypt", "data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte("dek"))}})
+	})
+
+	fv.Handle("/v1/transit/keys/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"request_id": "keys", "data": map[string]any{"latest_version": 1}})
+	})
+
+	for _, hardened := range []bool{false, true} {
+		vc, err := NewClient(
+			WithVaultAddress(fv.URL),
+			WithTokenAuth("token"),
+			WithTransit("transit", "kms"),
+			WithHardenedMemory(hardened),
+		)
+		require.NoError(t, err)
+
+		ctx := audit.NewContext(t.Context())
+
+		_, _, err = vc.Encrypt(ctx, []byte("dek"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"encrypt", "keys"}, audit.VaultRequestIDs(ctx))
+
+		ctx = audit.NewContext(t.Context())
+
+		_, err = vc.Decrypt(ctx, []byte("vault:v1:dek"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"decrypt"}, audit.VaultRequestIDs(ctx))
+	}
+}
+
+func TestTransitKeyVersionMetric(t *testing.T) {
+	fv := newFakeVault(t)
+
+	fv.Handle("/v1/transit/keys/kms", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"latest_version": 3}})
+	})
+
+	vc, err := NewClient(
+		WithVaultAddress(fv.URL),
+		WithTokenAuth("token"),
+		WithTransit("transit", "kms"),
+	)
+	require.NoError(t, err)
+
+	_, err = vc.GetKeyVersion(metrics.WithProvider(t.Context(), "primary"))
+	require.NoError(t, err)
+
+	m := &dto.Metric{}
+	require.NoError(t, metrics.TransitKeyVersion.WithLabelValues("primary", "transit", "kms").Write(m))
+	require.InDelta(t, 3, m.GetGauge().GetValue(), 0)
+}